@@ -0,0 +1,101 @@
+package main
+
+import "fmt"
+
+// table maps the direction a beam enters a tile in to the directions it leaves in. Directions
+// missing from the table carry straight on, and directions mapped to no exits are absorbed.
+type table map[direction][]direction
+
+type element struct {
+	exits  [4][]direction // indexed by direction.index
+	portal bool           // beams leave from the element's paired tile instead of this one
+}
+
+func newElement(t table) element {
+	var e element
+	for _, d := range directions {
+		exits, ok := t[d]
+		if !ok {
+			exits = []direction{d}
+		}
+		e.exits[d.index()] = exits
+	}
+
+	return e
+}
+
+var elements = map[rune]element{}
+
+// register adds a tile type to the contraption. Registering an existing tile replaces it.
+func register(v rune, t table) {
+	elements[v] = newElement(t)
+}
+
+// registerPortal adds a tile type that is placed in pairs; a beam entering one tile leaves from
+// the other, continuing in the directions given by t.
+func registerPortal(v rune, t table) {
+	e := newElement(t)
+	e.portal = true
+	elements[v] = e
+}
+
+func init() {
+	// puzzle tiles
+	register('.', table{})
+	register('|', table{RIGHT: {UP, DOWN}, LEFT: {UP, DOWN}})
+	register('-', table{UP: {LEFT, RIGHT}, DOWN: {LEFT, RIGHT}})
+	register('/', table{RIGHT: {UP}, DOWN: {LEFT}, LEFT: {DOWN}, UP: {RIGHT}})
+	register('\\', table{RIGHT: {DOWN}, DOWN: {RIGHT}, LEFT: {UP}, UP: {LEFT}})
+
+	// absorber
+	register('#', table{RIGHT: nil, DOWN: nil, LEFT: nil, UP: nil})
+	// one-way mirrors - reflect like '/' and '\' from their left side, pass beams from the right
+	register('{', table{RIGHT: {UP}, DOWN: {LEFT}})
+	register('}', table{RIGHT: {DOWN}, UP: {LEFT}})
+	// three-way splitter
+	register('+', table{
+		RIGHT: {UP, RIGHT, DOWN},
+		DOWN:  {RIGHT, DOWN, LEFT},
+		LEFT:  {DOWN, LEFT, UP},
+		UP:    {LEFT, UP, RIGHT},
+	})
+	// portal pairs
+	registerPortal('@', table{})
+}
+
+// contraption is a grid of registered elements with its portals paired up.
+type contraption struct {
+	grid     grid
+	elements [][]element
+	portals  map[point]point
+}
+
+func newContraption(g grid) contraption {
+	c := contraption{grid: g, elements: make([][]element, len(g)), portals: make(map[point]point)}
+
+	ends := make(map[rune][]point)
+	for y, row := range g {
+		c.elements[y] = make([]element, len(row))
+		for x, v := range row {
+			e, ok := elements[v]
+			if !ok {
+				panic(fmt.Sprintf("invalid input: unknown tile %q at %s", v, point{x, y}))
+			}
+			c.elements[y][x] = e
+
+			if e.portal {
+				ends[v] = append(ends[v], point{x, y})
+			}
+		}
+	}
+
+	for v, pair := range ends {
+		if len(pair) != 2 {
+			panic(fmt.Sprintf("invalid input: portal %q needs exactly two tiles, found %d", v, len(pair)))
+		}
+		c.portals[pair[0]] = pair[1]
+		c.portals[pair[1]] = pair[0]
+	}
+
+	return c
+}
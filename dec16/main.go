@@ -1,27 +1,36 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"math/bits"
 	"os"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 )
 
 func parseInput() []string {
-	raw, _ := os.ReadFile(os.Args[1])
+	raw, _ := os.ReadFile(flag.Arg(0))
 	return strings.Split(strings.Trim(string(raw), "\n"), "\n")
 }
 
-func parseGrid(input []string) [][]tile {
+type grid [][]rune
 
-	grid := make([][]tile, len(input))
+func parseGrid(input []string) grid {
+
+	g := make(grid, len(input))
 	for y, row := range input {
-		grid[y] = make([]tile, len(row))
-		for x, c := range row {
-			grid[y][x] = tile{v: c, beams: make(map[beam]struct{})}
-		}
+		g[y] = []rune(row)
 	}
 
-	return grid
+	return g
+}
+
+func (g grid) inbounds(p point) bool {
+	return p.y >= 0 && p.y < len(g) && p.x >= 0 && p.x < len(g[p.y])
 }
 
 type point struct {
@@ -32,6 +41,10 @@ func (p point) String() string {
 	return fmt.Sprintf("(%d,%d)", p.x, p.y)
 }
 
+func (p point) move(d direction) point {
+	return point{p.x + d.x, p.y + d.y}
+}
+
 type direction point
 
 var (
@@ -55,174 +68,278 @@ func (d direction) String() string {
 	return ""
 }
 
-type beam direction
+var directions = [4]direction{RIGHT, DOWN, LEFT, UP}
 
-type tile struct {
-	v     rune
-	beams map[beam]struct{}
+func (d direction) index() int {
+	switch d {
+	case RIGHT:
+		return 0
+	case DOWN:
+		return 1
+	case LEFT:
+		return 2
+	case UP:
+		return 3
+	}
+	panic("invalid direction")
 }
 
-func (t tile) String() string {
-	if t.v == '.' {
-		switch len(t.beams) {
-		case 0:
-			return string(t.v)
-		case 1:
-			for b := range t.beams {
-				return fmt.Sprintf("%s", direction(b))
-			}
-		default:
-			return fmt.Sprintf("%d", len(t.beams))
-		}
-	}
+// bit returns the direction's flag within a tile's beam bitmask.
+func (d direction) bit() uint8 {
+	return 1 << d.index()
+}
 
-	return string(t.v)
+type beam struct {
+	p point
+	d direction
 }
 
-func energize(grid [][]tile, p point, b beam) {
-	if p.y < 0 || p.y >= len(grid) || p.x < 0 || p.x >= len(grid[p.y]) { // out of bounds
-		return
-	}
+// segment is a straight run of a beam from one tile to another, inclusive.
+type segment struct {
+	from, to point
+	d        direction
+}
 
-	x, y := p.x, p.y
-	dx, dy := b.x, b.y
-	t := grid[y][x]
+func (s segment) String() string {
+	return fmt.Sprintf("%s%s%s", s.from, s.d, s.to)
+}
 
-	// base case - if a beam with same direction has been seen, stop recursion
-	if _, ok := t.beams[b]; ok {
-		return
+type trace struct {
+	beams    [][]uint8 // bitmask of the beam directions that entered each tile
+	emitted  [][]uint8 // bitmask of the beam directions that teleported out of each portal tile
+	segments []segment // only recorded when asked for
+}
+
+// at returns the bitmask of every beam direction that passed through the tile at p, whether it
+// walked in or teleported out.
+func (t trace) at(p point) uint8 {
+	return t.beams[p.y][p.x] | t.emitted[p.y][p.x]
+}
+
+// energize follows the beams entering the contraption at each source, recording the straight
+// segments they travel along if withSegments is set. Beams are traced with a work queue rather than
+// recursion, and stop once they enter a tile in a direction a beam has already entered it in.
+// Teleporting out of a portal tile is tracked apart from entering it, so a beam walking into a
+// portal tile that another beam teleported out of still teleports.
+func energize(c contraption, withSegments bool, sources ...beam) trace {
+	t := trace{beams: make([][]uint8, len(c.grid)), emitted: make([][]uint8, len(c.grid))}
+	for y, row := range c.grid {
+		t.beams[y] = make([]uint8, len(row))
+		t.emitted[y] = make([]uint8, len(row))
 	}
 
-	t.beams[b] = struct{}{}
+	queue := slices.Clone(sources)
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
 
-	switch t.v {
+		p, d := cur.p, cur.d
+		last, moved := p, false
+		for c.grid.inbounds(p) && t.beams[p.y][p.x]&d.bit() == 0 {
+			t.beams[p.y][p.x] |= d.bit()
+			last, moved = p, true
 
-	case '.':
-		energize(grid, point{x + dx, y + dy}, b)
-	case '|':
-		switch direction(b) {
-		case RIGHT, LEFT:
-			energize(grid, point{x + UP.x, y + UP.y}, beam(UP))
-			energize(grid, point{x + DOWN.x, y + DOWN.y}, beam(DOWN))
-		case UP, DOWN:
-			energize(grid, point{x + dx, y + dy}, b)
-		}
-	case '-':
-		switch direction(b) {
-		case RIGHT, LEFT:
-			energize(grid, point{x + dx, y + dy}, b)
-		case UP, DOWN:
-			energize(grid, point{x + LEFT.x, y + LEFT.y}, beam(LEFT))
-			energize(grid, point{x + RIGHT.x, y + RIGHT.y}, beam(RIGHT))
+			e := c.elements[p.y][p.x]
+			if e.portal {
+				break
+			}
+
+			next := e.exits[d.index()]
+			if len(next) == 1 && next[0] == d { // carry straight on within the same segment
+				p = p.move(d)
+				continue
+			}
+
+			for _, nd := range next {
+				queue = append(queue, beam{p.move(nd), nd})
+			}
+			break
 		}
-	case '/':
-		switch direction(b) {
-		case RIGHT:
-			energize(grid, point{x + UP.x, y + UP.y}, beam(UP))
-		case DOWN:
-			energize(grid, point{x + LEFT.x, y + LEFT.y}, beam(LEFT))
-		case LEFT:
-			energize(grid, point{x + DOWN.x, y + DOWN.y}, beam(DOWN))
-		case UP:
-			energize(grid, point{x + RIGHT.x, y + RIGHT.y}, beam(RIGHT))
+
+		if !moved {
+			continue
 		}
-	case '\\':
-		switch direction(b) {
-		case RIGHT:
-			energize(grid, point{x + DOWN.x, y + DOWN.y}, beam(DOWN))
-		case DOWN:
-			energize(grid, point{x + RIGHT.x, y + RIGHT.y}, beam(RIGHT))
-		case LEFT:
-			energize(grid, point{x + UP.x, y + UP.y}, beam(UP))
-		case UP:
-			energize(grid, point{x + LEFT.x, y + LEFT.y}, beam(LEFT))
+		if withSegments {
+			t.segments = append(t.segments, segment{from: cur.p, to: last, d: d})
 		}
-	default:
-		panic("invalid input")
 
-	}
-}
+		// the beam reappears on the paired portal tile and leaves from there
+		if e := c.elements[last.y][last.x]; e.portal {
+			exit := c.portals[last]
+			if t.emitted[exit.y][exit.x]&d.bit() != 0 {
+				continue
+			}
 
-func display(grid [][]tile) {
-	for _, row := range grid {
-		for _, t := range row {
-			fmt.Printf("%s", t)
+			t.emitted[exit.y][exit.x] |= d.bit()
+			if withSegments {
+				t.segments = append(t.segments, segment{from: exit, to: exit, d: d})
+			}
+			for _, nd := range e.exits[d.index()] {
+				queue = append(queue, beam{exit.move(nd), nd})
+			}
 		}
-		fmt.Println()
 	}
-}
 
-func copyTiles(in [][]tile) [][]tile {
-	var out [][]tile
-	for y, row := range in {
-		out = append(out, make([]tile, len(row)))
-		for x, c := range row {
-			out[y][x] = tile{v: c.v, beams: make(map[beam]struct{})}
-		}
-	}
-	return out
+	return t
 }
 
-func energized(grid [][]tile) int {
-	visited := 0
-	for _, row := range grid {
-		for _, t := range row {
-			if len(t.beams) > 0 {
-				visited++
+func (t trace) count() int {
+	count := 0
+	for y, row := range t.beams {
+		for x := range row {
+			if t.at(point{x, y}) != 0 {
+				count++
 			}
 		}
 	}
-	return visited
+
+	return count
 }
 
-func getStarts(grid [][]tile) map[point]direction {
+func display(g grid, t trace) {
+	for y, row := range g {
+		for x, v := range row {
+			mask := t.at(point{x, y})
+			if v != '.' || mask == 0 {
+				fmt.Printf("%c", v)
+				continue
+			}
 
-	edges := make(map[point]direction)
+			if n := bits.OnesCount8(mask); n > 1 {
+				fmt.Printf("%d", n)
+				continue
+			}
 
-	for y, row := range grid {
-		for x := range row {
-			if y == 0 { // top edge
-				edges[point{x, y}] = DOWN
-			} else if y == len(grid)-1 { // bottom edge
-				edges[point{x, y}] = UP
-			} else if x == 0 { // left edge
-				edges[point{x, y}] = RIGHT
-			} else if x == len(row)-1 {
-				edges[point{x, y}] = LEFT // right edge
+			for _, d := range directions {
+				if mask&d.bit() != 0 {
+					fmt.Printf("%s", d)
+				}
 			}
 		}
+		fmt.Println()
 	}
+}
+
+// getStarts returns every beam that can enter the grid from an edge. Corner tiles can be entered
+// from two sides, so a w*h grid has 2*(w+h) starts.
+func getStarts(g grid) []beam {
+	var starts []beam
 
-	return edges
+	h := len(g)
+	w := len(g[0])
+	for x := 0; x < w; x++ {
+		starts = append(starts, beam{point{x, 0}, DOWN})   // top edge
+		starts = append(starts, beam{point{x, h - 1}, UP}) // bottom edge
+	}
+	for y := 0; y < h; y++ {
+		starts = append(starts, beam{point{0, y}, RIGHT})    // left edge
+		starts = append(starts, beam{point{w - 1, y}, LEFT}) // right edge
+	}
+
+	return starts
 }
 
 func part1(input []string) int {
-	grid := parseGrid(input)
+	c := newContraption(parseGrid(input))
+
+	return energize(c, false, beam{point{0, 0}, RIGHT}).count()
+}
+
+type result struct {
+	index int // position of start within getStarts, so ties resolve the same way every run
+	start beam
+	count int
+}
+
+// part2 traces every edge start concurrently and returns the start energizing the most tiles.
+func part2(input []string) result {
+	g := parseGrid(input)
+	c := newContraption(g)
+	concurrency := runtime.NumCPU()
+
+	starts := getStarts(g)
+	indexes := make(chan int)
+	results := make(chan result)
+
+	worker := func() {
+		for i := range indexes {
+			results <- result{index: i, start: starts[i], count: energize(c, false, starts[i]).count()}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker()
+		}()
+	}
+
+	go func() {
+		for i := range starts {
+			indexes <- i
+		}
+		close(indexes)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var best result
+	for r := range results {
+		if r.count > best.count || r.count == best.count && r.index < best.index {
+			best = r
+		}
+	}
 
-	energize(grid, point{0, 0}, beam(RIGHT))
-	return energized(grid)
+	return best
 }
 
-func part2(input []string) int {
-	grid := parseGrid(input)
-	edges := getStarts(grid)
+// parseBeam parses a beam source like "0,0,>" into its starting point and direction.
+func parseBeam(raw string) (beam, error) {
+	var b beam
+	var d rune
+	if _, err := fmt.Sscanf(raw, "%d,%d,%c", &b.p.x, &b.p.y, &d); err != nil {
+		return b, fmt.Errorf("parsing beam %q: %w", raw, err)
+	}
 
-	max := 0
-	for p, d := range edges {
-		g := copyTiles(grid)
-		energize(g, p, beam(d))
-		count := energized(g)
-		if count > max {
-			max = count
+	for _, dir := range directions {
+		if dir.String() == string(d) {
+			b.d = dir
+			return b, nil
 		}
 	}
-	return max
+
+	return b, errors.New("beam direction must be one of >, v, < or ^")
 }
 
 func main() {
+	var sources []beam
+	flag.Func("beam", "trace a beam entering at `x,y,dir` (dir is >, v, < or ^) instead of solving, drawing the energized tiles and listing the straight segments travelled; repeat for simultaneous beams", func(raw string) error {
+		b, err := parseBeam(raw)
+		sources = append(sources, b)
+		return err
+	})
+	flag.Parse()
 
 	input := parseInput()
 
+	if len(sources) > 0 {
+		g := parseGrid(input)
+		t := energize(newContraption(g), true, sources...)
+		display(g, t)
+		fmt.Println("Energized:", t.count())
+		fmt.Println("Segments:")
+		for _, seg := range t.segments {
+			fmt.Println(" ", seg)
+		}
+		return
+	}
+
 	fmt.Println("Part 1:", part1(input))
-	fmt.Println("Part 2:", part2(input))
+	best := part2(input)
+	fmt.Println("Part 2:", best.count, "entering at", best.start.p, "heading", best.start.d)
 }
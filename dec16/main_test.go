@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEnergizePortalsSourceOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		grid    []string
+		sources []beam
+		want    int
+	}{
+		{
+			name:    "walk into a portal another beam teleported out of",
+			grid:    []string{"@.@.."},
+			sources: []beam{{point{0, 0}, RIGHT}, {point{2, 0}, RIGHT}},
+			want:    5,
+		},
+		{
+			name:    "portal exits in a column",
+			grid:    []string{"@", ".", "@", ".", "."},
+			sources: []beam{{point{0, 0}, DOWN}, {point{0, 2}, DOWN}},
+			want:    5,
+		},
+	}
+
+	for _, tt := range tests {
+		c := newContraption(parseGrid(tt.grid))
+
+		forward := energize(c, false, tt.sources...).count()
+		reversed := slices.Clone(tt.sources)
+		slices.Reverse(reversed)
+		backward := energize(c, false, reversed...).count()
+
+		if forward != tt.want || backward != tt.want {
+			t.Errorf("%s: energized %d, and %d with the sources reversed, want %d", tt.name, forward, backward, tt.want)
+		}
+	}
+}
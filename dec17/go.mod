@@ -5,4 +5,7 @@ go 1.21.4
 require (
 	github.com/hashicorp/golang-lru v1.0.2 // indirect
 	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+	github.com/nickshine/adventofcode2023/search v0.0.0
 )
+
+replace github.com/nickshine/adventofcode2023/search => ../search
@@ -1,16 +1,18 @@
 package main
 
 import (
-	"container/heap"
+	"flag"
 	"fmt"
 	"os"
 	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/nickshine/adventofcode2023/search"
 )
 
 func parseInput() []string {
-	raw, _ := os.ReadFile(os.Args[1])
+	raw, _ := os.ReadFile(flag.Arg(0))
 	return strings.Split(strings.Trim(string(raw), "\n"), "\n")
 }
 
@@ -22,11 +24,6 @@ func (p point) String() string {
 	return fmt.Sprintf("(%d,%d)", p.x, p.y)
 }
 
-type node struct {
-	loss  int
-	state state
-}
-
 type state struct {
 	loc point
 	d   direction
@@ -44,52 +41,38 @@ var (
 
 var directions = [4]direction{UP, DOWN, LEFT, RIGHT}
 
-var turns = map[direction][]direction{
-	UP:    {LEFT, RIGHT},
-	DOWN:  {LEFT, RIGHT},
-	LEFT:  {UP, DOWN},
-	RIGHT: {UP, DOWN},
-}
-
-var opposites = map[direction]direction{
-	LEFT:  RIGHT,
-	RIGHT: LEFT,
-	UP:    DOWN,
-	DOWN:  UP,
-}
-
-// priority queue - https://pkg.go.dev/container/heap
-type queue []*node
-
-func (q queue) Len() int           { return len(q) }
-func (q queue) Less(i, j int) bool { return q[i].loss < q[j].loss }
-func (q queue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
-
-func (q *queue) Push(x any) {
-	*q = append(*q, x.(*node))
-}
-
-func (q *queue) Pop() any {
-	old := *q
-	n := len(old)
-	item := old[n-1]
-	old[n-1] = nil // avoid memory leak
-	*q = old[0 : n-1]
-	return item
+func (d direction) String() string {
+	switch d {
+	case UP:
+		return "^"
+	case DOWN:
+		return "v"
+	case LEFT:
+		return "<"
+	case RIGHT:
+		return ">"
+	case UPRIGHT, DOWNLEFT:
+		return "/"
+	case DOWNRIGHT, UPLEFT:
+		return "\\"
+	}
+	return ""
 }
 
 type grid [][]int
 
 func (g grid) inbounds(x, y int) bool {
-	if len(g) == 0 {
-		return false
-	}
+	return y >= 0 && y < len(g) && x >= 0 && x < len(g[y])
+}
 
-	return x >= 0 && x < len(g[0]) && y >= 0 && y < len(g)
+// corners returns the top-left and bottom-right points of the grid, the default start and end.
+func (g grid) corners() (point, point) {
+	last := len(g) - 1
+	return point{0, 0}, point{len(g[last]) - 1, last}
 }
 
-func parseGrid(input []string) [][]int {
-	var grid [][]int
+func parseGrid(input []string) grid {
+	var grid grid
 
 	for y, row := range input {
 		grid = append(grid, make([]int, len(row)))
@@ -102,104 +85,187 @@ func parseGrid(input []string) [][]int {
 	return grid
 }
 
-// choices filters the available next path steps based on current direction and move count.
-func choices(g grid, cur *node, states map[state]int, minMv, maxMv int) []*node {
-	var choices []*node
+// choices filters the available next path steps based on current direction, move count and the
+// vehicle's movement rules.
+func choices(g grid, cur state, r rules) []search.Edge[state] {
+	var choices []search.Edge[state]
 
-	x, y := cur.state.loc.x, cur.state.loc.y
+	x, y := cur.loc.x, cur.loc.y
+	minRun, maxRun := r.run(cur.d)
 
-	for _, d := range directions {
+	for _, d := range r.headings() {
 		if !g.inbounds(x+d.x, y+d.y) {
 			continue
 		}
 
-		// if we can no longer travel in direction d, or d is not a valid turn, continue
-		if cur.state.mv == maxMv && !slices.Contains(turns[cur.state.d], d) || opposites[cur.state.d] == d {
-			continue
-		}
-
-		// must travel minMv steps in same direction before turning
-		if d != cur.state.d && cur.state.mv < minMv {
-			continue
+		nextMv := 1 // assumes a turn - start over moves when turning
+		penalty := 0
+		if d == cur.d {
+			// if we can no longer travel in direction d, continue
+			if cur.mv >= maxRun {
+				continue
+			}
+			nextMv = cur.mv + 1
+		} else {
+			// if d is not a valid turn, continue
+			p, ok := r.turns[turn(cur.d, d)]
+			if !ok {
+				continue
+			}
+
+			// must travel minRun steps in same direction before turning
+			if cur.mv < minRun {
+				continue
+			}
+			penalty = p
 		}
 
 		nx, ny := x+d.x, y+d.y
 
-		nextMv := 1 // assumes a turn - start over moves when turning
-		if d == cur.state.d {
-			nextMv = cur.state.mv%maxMv + 1
-		}
-
 		nextState := state{loc: point{nx, ny}, d: d, mv: nextMv}
-		nextLoss := g[ny][nx]
-		// if next loc is visited with the exact same direction and mv count, and it has a lower loss, abandon this path
-		if loss, ok := states[nextState]; ok && loss <= cur.loss+nextLoss {
-			continue
-		}
-
-		states[nextState] = cur.loss + nextLoss
-		choices = append(choices, &node{state: nextState, loss: cur.loss + nextLoss})
+		choices = append(choices, search.Edge[state]{To: nextState, Cost: g[ny][nx] + penalty})
 	}
 
 	return choices
 
 }
 
-func dijkstra(g grid, start, end point, minMv, maxMv int) int {
-	start1 := state{loc: start, d: RIGHT, mv: 0}
-	start2 := state{loc: start, d: DOWN, mv: 0}
+// dijkstra returns the minimum heat loss from any of the starts to any of the ends along with the
+// route taking it (excluding the start), or -1 and a nil route if no end can be reached.
+func dijkstra(g grid, starts, ends []point, r rules) (int, []state) {
+	// a vehicle may set off in any direction from a start
+	var initial []state
+	for _, start := range starts {
+		for _, d := range r.headings() {
+			initial = append(initial, state{loc: start, d: d, mv: 0})
+		}
+	}
 
-	queue := queue{
-		&node{loss: 0, state: start1},
-		&node{loss: 0, state: start2},
+	neighbors := func(s state) []search.Edge[state] {
+		return choices(g, s, r)
 	}
 
-	// map of states to minimum loss
-	states := map[state]int{start1: 0, start2: 0}
-	heap.Init(&queue)
+	// at an end and able to stop
+	goal := func(s state) bool {
+		return slices.Contains(ends, s.loc) && r.canStop(s)
+	}
 
-	for len(queue) > 0 {
-		cur := heap.Pop(&queue).(*node)
-		// abandon path if there is already a lower loss path
-		if states[cur.state] < cur.loss {
-			continue
-		}
+	result, ok := search.Dijkstra(initial, neighbors, goal)
+	if !ok {
+		return -1, nil
+	}
 
-		// if at end and able to stop
-		if cur.state.loc == end && cur.state.mv >= minMv {
-			return cur.loss
-		}
+	return result.Cost, result.Path[1:]
+}
 
-		for _, c := range choices(g, cur, states, minMv, maxMv) {
-			heap.Push(&queue, c)
+// render draws the grid with each step of the route replaced by the direction it was entered in.
+func render(g grid, route []state) string {
+	steps := make(map[point]direction, len(route))
+	for _, s := range route {
+		steps[s.loc] = s.d
+	}
+
+	var sb strings.Builder
+	for y, row := range g {
+		for x, loss := range row {
+			if d, ok := steps[point{x, y}]; ok {
+				sb.WriteString(d.String())
+			} else {
+				sb.WriteString(strconv.Itoa(loss))
+			}
 		}
+		sb.WriteString("\n")
 	}
 
-	return -1
+	return sb.String()
 }
 
-func part1(input []string) int {
+func part1(input []string, starts, ends []point) (int, []state) {
 	grid := parseGrid(input)
+	starts, ends = endpoints(grid, starts, ends)
 
-	start := point{0, 0}
-	end := point{len(grid) - 1, len(grid[0]) - 1}
-	weight := dijkstra(grid, start, end, 0, 3)
-	return weight
+	return dijkstra(grid, starts, ends, vehicles["crucible"])
 }
 
-func part2(input []string) int {
+func part2(input []string, starts, ends []point) (int, []state) {
 	grid := parseGrid(input)
+	starts, ends = endpoints(grid, starts, ends)
 
-	start := point{0, 0}
-	end := point{len(grid) - 1, len(grid[0]) - 1}
-	weight := dijkstra(grid, start, end, 4, 10)
-	return weight
+	return dijkstra(grid, starts, ends, vehicles["ultra"])
+}
+
+// endpoints fills in the grid corners for missing starts or ends.
+func endpoints(g grid, starts, ends []point) ([]point, []point) {
+	start, end := g.corners()
+	if len(starts) == 0 {
+		starts = []point{start}
+	}
+	if len(ends) == 0 {
+		ends = []point{end}
+	}
+
+	return starts, ends
+}
+
+// pointFlag collects repeated `x,y` flag values.
+type pointFlag []point
+
+func (f *pointFlag) String() string {
+	return fmt.Sprint(*f)
+}
+
+func (f *pointFlag) Set(raw string) error {
+	var p point
+	if _, err := fmt.Sscanf(raw, "%d,%d", &p.x, &p.y); err != nil {
+		return fmt.Errorf("parsing point %q: %w", raw, err)
+	}
+	*f = append(*f, p)
+
+	return nil
 }
 
 func main() {
+	var starts, ends pointFlag
+	flag.Var(&starts, "start", "start the crucible at `x,y`; repeat for multiple starts (default top-left corner)")
+	flag.Var(&ends, "end", "deliver the crucible to `x,y`; repeat for multiple ends (default bottom-right corner)")
+	draw := flag.Bool("render", false, "draw the route taken by each crucible")
+	vehicle := flag.String("vehicle", "", "solve for the named vehicle instead of both parts (one of "+strings.Join(vehicleNames(), ", ")+")")
+	flag.Parse()
 
 	input := parseInput()
+	grid := parseGrid(input)
+	for _, p := range append(slices.Clone(starts), ends...) {
+		if !grid.inbounds(p.x, p.y) {
+			fmt.Fprintf(os.Stderr, "point %s is outside the %dx%d grid\n", p, len(grid[0]), len(grid))
+			os.Exit(2)
+		}
+	}
+
+	if *vehicle != "" {
+		r, ok := vehicles[*vehicle]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown vehicle %q\n", *vehicle)
+			os.Exit(2)
+		}
+
+		s, e := endpoints(grid, starts, ends)
+		loss, route := dijkstra(grid, s, e, r)
+		fmt.Printf("%s: %d\n", *vehicle, loss)
+		if *draw {
+			fmt.Print(render(grid, route))
+		}
+		return
+	}
 
-	fmt.Println("Part 1:", part1(input))
-	fmt.Println("Part 2:", part2(input))
+	loss1, route1 := part1(input, starts, ends)
+	fmt.Println("Part 1:", loss1)
+	if *draw {
+		fmt.Print(render(grid, route1))
+	}
+
+	loss2, route2 := part2(input, starts, ends)
+	fmt.Println("Part 2:", loss2)
+	if *draw {
+		fmt.Print(render(grid, route2))
+	}
 }
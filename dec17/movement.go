@@ -0,0 +1,82 @@
+package main
+
+import "slices"
+
+var (
+	UPRIGHT   = direction{1, -1}
+	DOWNRIGHT = direction{1, 1}
+	DOWNLEFT  = direction{-1, 1}
+	UPLEFT    = direction{-1, -1}
+)
+
+// compass lists every heading clockwise from UP, so the turn between two headings is the
+// difference of their indexes.
+var compass = [8]direction{UP, UPRIGHT, RIGHT, DOWNRIGHT, DOWN, DOWNLEFT, LEFT, UPLEFT}
+
+// turn returns how far clockwise, in eighths of a full turn, heading to is from heading from.
+// 2 and 6 are right and left turns, and 4 is reversing.
+func turn(from, to direction) int {
+	var i, j int
+	for k, d := range compass {
+		if d == from {
+			i = k
+		}
+		if d == to {
+			j = k
+		}
+	}
+
+	return (j - i + len(compass)) % len(compass)
+}
+
+// rules describes how a vehicle may move across the grid.
+type rules struct {
+	diagonal bool                 // may travel diagonally as well as along rows and columns
+	turns    map[int]int          // allowed turns (see turn) to the extra heat loss taking them costs
+	minRun   int                  // moves required in a direction before turning or stopping
+	maxRun   int                  // moves allowed in a direction before having to turn
+	runs     map[direction][2]int // per-direction overrides of {minRun, maxRun}
+}
+
+var vehicles = map[string]rules{
+	"crucible": {turns: map[int]int{2: 0, 6: 0}, maxRun: 3},
+	"ultra":    {turns: map[int]int{2: 0, 6: 0}, minRun: 4, maxRun: 10},
+	// can back up, but changing gear costs time
+	"tank": {turns: map[int]int{2: 0, 4: 5, 6: 0}, maxRun: 5},
+	// can only turn right
+	"zoolander": {turns: map[int]int{2: 0}, maxRun: 3},
+	// drifts diagonally for free, but sharp turns cost extra
+	"rover": {diagonal: true, turns: map[int]int{1: 0, 2: 2, 6: 2, 7: 0}, maxRun: 4},
+	// builds speed downhill, struggles uphill
+	"sled": {turns: map[int]int{2: 0, 6: 0}, maxRun: 3, runs: map[direction][2]int{DOWN: {0, 10}, UP: {0, 1}}},
+}
+
+func (r rules) headings() []direction {
+	if r.diagonal {
+		return compass[:]
+	}
+	return directions[:]
+}
+
+func (r rules) run(d direction) (int, int) {
+	if run, ok := r.runs[d]; ok {
+		return run[0], run[1]
+	}
+	return r.minRun, r.maxRun
+}
+
+// canStop reports whether a vehicle in state s has travelled far enough in its direction to stop.
+func (r rules) canStop(s state) bool {
+	minRun, _ := r.run(s.d)
+	return s.mv >= minRun
+}
+
+func vehicleNames() []string {
+	names := make([]string, 0, len(vehicles))
+	for name := range vehicles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
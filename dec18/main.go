@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
@@ -8,7 +9,7 @@ import (
 )
 
 func parseInput() []string {
-	raw, _ := os.ReadFile(os.Args[1])
+	raw, _ := os.ReadFile(flag.Arg(0))
 	return strings.Split(strings.Trim(string(raw), "\n"), "\n")
 }
 
@@ -49,77 +50,154 @@ func parseDirection(d string) direction {
 	return out
 }
 
-func parsePoints(in []string) []point {
+type instruction struct {
+	dir    direction
+	length int
+	color  string // trench color, e.g. #70c710
+}
 
-	var points []point
-	x, y := 0, 0
+func parsePlan(in []string) []instruction {
+
+	var plan []instruction
 
 	for _, line := range in {
 		parts := strings.Split(line, " ")
 		dir := parseDirection(parts[0])
 		count, _ := strconv.Atoi(parts[1])
+		color := strings.Trim(parts[2], "()")
 
-		for i := 0; i < count; i++ {
-			x, y = x+dir.x, y+dir.y
-			points = append(points, point{x, y})
-		}
+		plan = append(plan, instruction{dir, count, color})
 	}
 
-	return points
+	return plan
 }
 
-func parseHexPoints(in []string) []point {
+// parseHexPlan reads the real instructions hidden in the hex codes: the first five digits are the
+// length and the last is the direction.
+func parseHexPlan(in []string) []instruction {
 
-	var points []point
-	x, y := 0, 0
+	var plan []instruction
 
 	for _, line := range in {
 		parts := strings.Split(line, " ")
-		hex := strings.TrimPrefix(parts[2], "(#")
-		hex = strings.TrimSuffix(hex, ")")
+		color := strings.Trim(parts[2], "()")
+		hex := strings.TrimPrefix(color, "#")
 		count, _ := strconv.ParseInt(hex[:5], 16, 64)
 		dir := parseDirection(hex[5:])
 
-		for i := 0; i < int(count); i++ {
-			x, y = x+dir.x, y+dir.y
-			points = append(points, point{x, y})
-		}
+		plan = append(plan, instruction{dir, int(count), color})
+	}
+
+	return plan
+}
+
+// vertices returns the corner reached after each instruction, starting from the origin. The
+// last vertex is back at the origin for a closed plan.
+func vertices(plan []instruction) []point {
+	points := make([]point, 0, len(plan))
+	x, y := 0, 0
+
+	for _, ins := range plan {
+		x, y = x+ins.dir.x*ins.length, y+ins.dir.y*ins.length
+		points = append(points, point{x, y})
 	}
 
 	return points
 }
 
-func solve(points []point) int {
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// shoelace returns twice the signed area enclosed by the vertices - positive when they run
+// clockwise on screen (y down), negative when counter-clockwise.
+func shoelace(points []point) int {
 	// https://en.wikipedia.org/wiki/Shoelace_formula
 	sum := 0
-	for i := 0; i < len(points)-1; i++ {
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		sum += p.x*q.y - q.x*p.y
+	}
+
+	return sum
+}
 
-		sum += (points[i].y + points[i+1].y) * (points[i].x - points[i+1].x)
+func perimeter(plan []instruction) int {
+	length := 0
+	for _, ins := range plan {
+		length += ins.length
 	}
-	sum += (points[len(points)-1].y + points[0].y) * (points[len(points)-1].x - points[0].x)
-	area := sum / 2
 
-	// https://en.wikipedia.org/wiki/Pick's_theorem
-	inside := area - len(points)/2 + 1
-	outside := len(points)
+	return length
+}
 
-	return inside + outside
+func solve(plan []instruction) int {
+	area := abs(shoelace(vertices(plan))) / 2
+	boundary := perimeter(plan)
+
+	// https://en.wikipedia.org/wiki/Pick's_theorem
+	inside := area - boundary/2 + 1
 
+	return inside + boundary
 }
 
 func part1(in []string) int {
-	points := parsePoints(in)
-	return solve(points)
+	return solve(parsePlan(in))
 }
 
 func part2(in []string) int {
-	points := parseHexPoints(in)
-	return solve(points)
+	return solve(parseHexPlan(in))
 }
 
 func main() {
+	svg := flag.String("svg", "", "write the dig plan as an SVG to `file`")
+	hex := flag.Bool("hex", false, "draw the plan decoded from the hex codes (part 2) rather than part 1's")
+	check := flag.Bool("check", false, "report whether each plan is a valid loop and which way it is dug, then exit")
+	flag.Parse()
+
 	input := parseInput()
 
+	invalid := false
+	for i, plan := range [][]instruction{parsePlan(input), parseHexPlan(input)} {
+		o, err := validate(plan)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Part %d: invalid dig plan: %v\n", i+1, err)
+			invalid = true
+		case *check:
+			fmt.Printf("Part %d: valid %s loop\n", i+1, o)
+		}
+	}
+	if *check {
+		return
+	}
+
+	if *svg != "" {
+		plan := parsePlan(input)
+		if *hex {
+			plan = parseHexPlan(input)
+		}
+
+		f, err := os.Create(*svg)
+		if err == nil {
+			err = writeSVG(f, plan)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if invalid {
+		os.Exit(1)
+	}
+
 	fmt.Println("Part 1:", part1(input))
 	fmt.Println("Part 2:", part2(input))
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeSVG draws the dig plan: the lagoon interior as a filled polygon, each trench segment as a
+// polyline in its own color, and the lagoon volume as a label. Points sit at the center of each
+// dug cube, so the drawing runs through the middle of the trench.
+func writeSVG(w io.Writer, plan []instruction) error {
+	points := append([]point{{0, 0}}, vertices(plan)...)
+
+	minX, minY, maxX, maxY := 0, 0, 0, 0
+	for _, p := range points {
+		minX, maxX = min(minX, p.x), max(maxX, p.x)
+		minY, maxY = min(minY, p.y), max(maxY, p.y)
+	}
+
+	width, height := maxX-minX+1, maxY-minY+1
+	size := max(width, height)
+	pad := max(size/20, 1)
+	fontSize := max(size/25, 1)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%d %d %d %d" width="800" height="%d">`+"\n",
+		minX-pad, minY-pad, width+2*pad, height+2*pad+2*fontSize, 800*(height+2*pad+2*fontSize)/(width+2*pad))
+
+	var corners []string
+	for _, p := range points[:len(points)-1] {
+		corners = append(corners, fmt.Sprintf("%d,%d", p.x, p.y))
+	}
+	fmt.Fprintf(&b, `  <polygon points="%s" fill="#dbe9f6" stroke="none"/>`+"\n", strings.Join(corners, " "))
+
+	for i, ins := range plan {
+		from, to := points[i], points[i+1]
+		fmt.Fprintf(&b, `  <polyline points="%d,%d %d,%d" stroke="%s" stroke-width="3" stroke-linecap="square" vector-effect="non-scaling-stroke" fill="none"/>`+"\n",
+			from.x, from.y, to.x, to.y, ins.color)
+	}
+
+	fmt.Fprintf(&b, `  <text x="%d" y="%d" font-family="monospace" font-size="%d">lagoon: %d m³</text>`+"\n",
+		minX, maxY+pad+fontSize, fontSize, solve(plan))
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+type orientation int
+
+const (
+	clockwise orientation = iota
+	counterClockwise
+)
+
+func (o orientation) String() string {
+	if o == clockwise {
+		return "clockwise"
+	}
+	return "counter-clockwise"
+}
+
+type segment struct {
+	from, to point
+}
+
+func (s segment) horizontal() bool {
+	return s.from.y == s.to.y
+}
+
+// bounds returns the top-left and bottom-right corners of the segment.
+func (s segment) bounds() (point, point) {
+	return point{min(s.from.x, s.to.x), min(s.from.y, s.to.y)},
+		point{max(s.from.x, s.to.x), max(s.from.y, s.to.y)}
+}
+
+// overlap returns the box where two segments touch, and false if they don't.
+func (s segment) overlap(o segment) (point, point, bool) {
+	smin, smax := s.bounds()
+	omin, omax := o.bounds()
+
+	lo := point{max(smin.x, omin.x), max(smin.y, omin.y)}
+	hi := point{min(smax.x, omax.x), min(smax.y, omax.y)}
+
+	return lo, hi, lo.x <= hi.x && lo.y <= hi.y
+}
+
+// validate checks the plan digs a simple closed loop - one that returns to the start and never
+// crosses, touches or runs back along itself - and returns the direction it is dug in. The area
+// from solve is only meaningful for valid plans.
+func validate(plan []instruction) (orientation, error) {
+	if len(plan) == 0 {
+		return clockwise, errors.New("plan has no instructions")
+	}
+
+	for i, ins := range plan {
+		if ins.length <= 0 {
+			return clockwise, fmt.Errorf("instruction %d has length %d", i+1, ins.length)
+		}
+	}
+
+	points := append([]point{{0, 0}}, vertices(plan)...)
+	if end := points[len(points)-1]; end != points[0] {
+		return clockwise, fmt.Errorf("plan ends at (%d,%d) instead of returning to the start", end.x, end.y)
+	}
+
+	segments := make([]segment, len(plan))
+	for i := range plan {
+		segments[i] = segment{points[i], points[i+1]}
+	}
+
+	for i, s := range segments {
+		for j := i + 1; j < len(segments); j++ {
+			o := segments[j]
+			lo, hi, ok := s.overlap(o)
+			if !ok {
+				continue
+			}
+
+			// consecutive instructions, including the last and first, share a corner
+			adjacent := j == i+1 || i == 0 && j == len(segments)-1
+			if adjacent && lo == hi {
+				continue
+			}
+
+			if s.horizontal() == o.horizontal() {
+				if lo == hi { // end to end along the same line
+					return clockwise, fmt.Errorf("instructions %d and %d touch at (%d,%d)", i+1, j+1, lo.x, lo.y)
+				}
+				return clockwise, fmt.Errorf("instructions %d and %d overlap between (%d,%d) and (%d,%d)", i+1, j+1, lo.x, lo.y, hi.x, hi.y)
+			}
+			return clockwise, fmt.Errorf("instructions %d and %d cross at (%d,%d)", i+1, j+1, lo.x, lo.y)
+		}
+	}
+
+	// a simple loop of non-zero length segments always encloses some area
+	if shoelace(points[:len(points)-1]) < 0 {
+		return counterClockwise, nil
+	}
+	return clockwise, nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type issueKind int
+
+const (
+	undefinedWorkflow issueKind = iota
+	undefinedCategory
+	cycle
+	unreachableWorkflow
+	deadRule
+	noFallback
+)
+
+func (k issueKind) String() string {
+	switch k {
+	case undefinedWorkflow:
+		return "undefined workflow"
+	case undefinedCategory:
+		return "undefined category"
+	case cycle:
+		return "cycle"
+	case unreachableWorkflow:
+		return "unreachable workflow"
+	case deadRule:
+		return "dead rule"
+	case noFallback:
+		return "no fallback"
+	}
+	return ""
+}
+
+type issue struct {
+	kind     issueKind
+	workflow string
+	rule     int // index of the offending rule within the workflow, or -1
+	detail   string
+}
+
+func (i issue) String() string {
+	loc := i.workflow
+	if i.rule >= 0 {
+		loc = fmt.Sprintf("%s rule %d", i.workflow, i.rule+1)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.kind, loc, i.detail)
+}
+
+// fatal reports whether the issue would keep process or processCombos from terminating correctly.
+func (i issue) fatal() bool {
+	return i.kind == undefinedWorkflow || i.kind == undefinedCategory || i.kind == cycle
+}
+
+func terminal(flowName string) bool {
+	return flowName == "A" || flowName == "R"
+}
+
+// flowNames returns the workflow names in sorted order so reports are stable.
+func flowNames(flows map[string][]rule) []string {
+	names := make([]string, 0, len(flows))
+	for name := range flows {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// validate reports undefined references, cycles, workflows unreachable from "in", rules that can
+// never fire because earlier rules in the same workflow already cover every rating in the universe
+// that would match them, and workflows without an unconditional last rule, which silently reject
+// parts matching none of their rules.
+func validate(flows map[string][]rule, universe ranges) []issue {
+	var issues []issue
+
+	// undeclared categories are reported below, then analyzed as if declared so dead rule
+	// detection can still run
+	universe = copyMap(universe)
+
+	if _, ok := flows["in"]; !ok {
+		issues = append(issues, issue{undefinedWorkflow, "in", -1, "no starting workflow"})
+	}
+
+	for _, name := range flowNames(flows) {
+		for i, r := range flows[name] {
+			if _, ok := flows[r.dest]; !ok && !terminal(r.dest) {
+				issues = append(issues, issue{undefinedWorkflow, name, i, fmt.Sprintf("destination %q is not defined", r.dest)})
+			}
+
+			for _, clause := range r.cond {
+				for _, cmp := range clause {
+					if _, ok := universe[cmp.category]; !ok {
+						issues = append(issues, issue{undefinedCategory, name, i, fmt.Sprintf("category %q is not declared by the ratings", cmp.category)})
+						universe[cmp.category] = [2]int{minRating, maxRating}
+					}
+				}
+			}
+		}
+	}
+
+	issues = append(issues, findCycles(flows)...)
+
+	reachable := reachableFrom(flows, "in")
+	for _, name := range flowNames(flows) {
+		if _, ok := reachable[name]; !ok {
+			issues = append(issues, issue{unreachableWorkflow, name, -1, `not reachable from "in"`})
+		}
+	}
+
+	for _, name := range flowNames(flows) {
+		if rules := flows[name]; len(rules) == 0 || len(rules[len(rules)-1].cond) > 0 {
+			issues = append(issues, issue{noFallback, name, -1, "no unconditional last rule, so parts matching no rule are rejected"})
+		}
+	}
+
+	for _, name := range flowNames(flows) {
+		for _, i := range deadRules(flows[name], universe) {
+			issues = append(issues, issue{deadRule, name, i, fmt.Sprintf("%s can never match after earlier rules", flows[name][i])})
+		}
+	}
+
+	return issues
+}
+
+// findCycles runs a depth-first search from every workflow, reporting each back edge as the cycle
+// it closes.
+func findCycles(flows map[string][]rule) []issue {
+	const (
+		unvisited = iota
+		active
+		done
+	)
+
+	var issues []issue
+	state := make(map[string]int, len(flows))
+	var path []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = active
+		path = append(path, name)
+
+		for _, r := range flows[name] {
+			if _, ok := flows[r.dest]; !ok {
+				continue
+			}
+
+			switch state[r.dest] {
+			case unvisited:
+				visit(r.dest)
+			case active:
+				loop := append(slices.Clone(path[slices.Index(path, r.dest):]), r.dest)
+				issues = append(issues, issue{cycle, r.dest, -1, strings.Join(loop, " -> ")})
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = done
+	}
+
+	for _, name := range flowNames(flows) {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return issues
+}
+
+func reachableFrom(flows map[string][]rule, start string) map[string]struct{} {
+	seen := make(map[string]struct{})
+	queue := []string{start}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		rules, ok := flows[name]
+		if _, visited := seen[name]; visited || !ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		for _, r := range rules {
+			queue = append(queue, r.dest)
+		}
+	}
+
+	return seen
+}
+
+// deadRules returns the indexes of rules that no rating in the universe can reach and satisfy.
+func deadRules(rules []rule, universe ranges) []int {
+	var dead []int
+
+	remaining := []ranges{universe}
+	for i, r := range rules {
+		var rejected []ranges
+		fires := false
+		for _, cur := range remaining {
+			pass, fail := r.cond.split(cur)
+			fires = fires || len(pass) > 0
+			rejected = append(rejected, fail...)
+		}
+
+		if !fires {
+			dead = append(dead, i)
+		}
+		remaining = rejected
+	}
+
+	return dead
+}
+
+// simplify returns a copy of flows where every workflow whose rules all lead to the same
+// destination, ending with an unconditional rule, is removed and references to it point straight at that destination. Collapsing
+// repeats until no uniform workflow remains. The "in" workflow is kept as a single standalone rule
+// so processing still has a place to start.
+func simplify(flows map[string][]rule) map[string][]rule {
+	out := make(map[string][]rule, len(flows))
+	for name, rules := range flows {
+		out[name] = slices.Clone(rules)
+	}
+
+	for {
+		aliases := make(map[string]string)
+		for name, rules := range out {
+			if dest, ok := uniformDest(rules); ok && dest != name {
+				aliases[name] = dest
+			}
+		}
+
+		changed := false
+		for name, rules := range out {
+			for i, r := range rules {
+				if dest := resolveAlias(aliases, r.dest); dest != r.dest {
+					rules[i].dest = dest
+					changed = true
+				}
+			}
+
+			if dest, ok := uniformDest(rules); ok && len(rules) > 1 {
+				out[name] = []rule{{dest: dest}}
+				changed = true
+			}
+		}
+
+		for name := range aliases {
+			if name != "in" && !referenced(out, name) {
+				delete(out, name)
+				changed = true
+			}
+		}
+
+		if !changed {
+			return out
+		}
+	}
+}
+
+// uniformDest returns the destination every part entering a workflow ends up at, if all its rules
+// share one. Without an unconditional last rule, parts matching nothing are rejected instead.
+func uniformDest(rules []rule) (string, bool) {
+	if len(rules) == 0 || len(rules[len(rules)-1].cond) > 0 {
+		return "", false
+	}
+
+	for _, r := range rules[1:] {
+		if r.dest != rules[0].dest {
+			return "", false
+		}
+	}
+
+	return rules[0].dest, true
+}
+
+// resolveAlias follows collapsed workflows to their final destination. If the aliases loop back on
+// themselves there is no final destination, and name is returned unchanged so the cycle is kept
+// as written.
+func resolveAlias(aliases map[string]string, name string) string {
+	seen := map[string]struct{}{name: {}}
+	for cur := name; ; {
+		next, ok := aliases[cur]
+		if !ok {
+			return cur
+		}
+		if _, loop := seen[next]; loop {
+			return name
+		}
+		seen[next] = struct{}{}
+		cur = next
+	}
+}
+
+func referenced(flows map[string][]rule, target string) bool {
+	for _, rules := range flows {
+		for _, r := range rules {
+			if r.dest == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatWorkflows(flows map[string][]rule) string {
+	var sb strings.Builder
+	for _, name := range flowNames(flows) {
+		var rules []string
+		for _, r := range flows[name] {
+			rules = append(rules, r.String())
+		}
+		fmt.Fprintf(&sb, "%s{%s}\n", name, strings.Join(rules, ","))
+	}
+
+	return sb.String()
+}
@@ -1,15 +1,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 func parseInput() ([]string, []string) {
-	raw, _ := os.ReadFile(os.Args[1])
+	raw, _ := os.ReadFile(flag.Arg(0))
 	parts := strings.Split(strings.Trim(string(raw), "\n"), "\n\n")
 
 	workflows := strings.Split(strings.Trim(parts[0], "\n"), "\n")
@@ -20,44 +22,242 @@ func parseInput() ([]string, []string) {
 }
 
 var (
-	workflowRE  = regexp.MustCompile(`^(\w+){(.*)}$`)
-	ratingsRE   = regexp.MustCompile(`^{x=(\d+),m=(\d+),a=(\d+),s=(\d+)}$`)
-	conditionRE = regexp.MustCompile(`^([xmas])([<>])(\d+)$`)
+	workflowRE   = regexp.MustCompile(`^(\w+){(.*)}$`)
+	ratingsRE    = regexp.MustCompile(`^{(.*)}$`)
+	ratingRE     = regexp.MustCompile(`^(\w+)=(-?\d+)$`)
+	comparisonRE = regexp.MustCompile(`^(\w+)(<=|>=|==|!=|<|>)(-?\d+)$`)
 )
 
-type part struct {
-	x, m, a, s int
-}
+const (
+	minRating = 1
+	maxRating = 4000
+)
+
+// part maps each category declared in the ratings (x, m, a, s, ...) to its rating.
+type part map[string]int
 
 func (p part) sum() int {
-	return p.x + p.m + p.a + p.s
+	sum := 0
+	for _, v := range p {
+		sum += v
+	}
+	return sum
+}
+
+func (p part) rating(category string) int {
+	v, ok := p[category]
+	if !ok {
+		panic("invalid input")
+	}
+	return v
 }
 
-func (p part) rating(category rune) int {
+// ranges maps each category to an inclusive [min, max] interval of ratings.
+type ranges map[string][2]int
+
+// negations maps each operator to the operator matching exactly the values it rejects.
+var negations = map[string]string{
+	"<":  ">=",
+	">=": "<",
+	">":  "<=",
+	"<=": ">",
+	"==": "!=",
+	"!=": "==",
+}
+
+type comparison struct {
+	category string
+	operator string
+	value    int
+}
 
-	switch category {
-	case 'x':
-		return p.x
-	case 'm':
-		return p.m
-	case 'a':
-		return p.a
-	case 's':
-		return p.s
+func (c comparison) String() string {
+	return fmt.Sprintf("%s%s%d", c.category, c.operator, c.value)
+}
+
+func (c comparison) eval(p part) bool {
+	r := p.rating(c.category)
+
+	switch c.operator {
+	case "<":
+		return r < c.value
+	case "<=":
+		return r <= c.value
+	case ">":
+		return r > c.value
+	case ">=":
+		return r >= c.value
+	case "==":
+		return r == c.value
+	case "!=":
+		return r != c.value
+	default:
+		panic("invalid input")
+	}
+}
+
+// intervals returns the non-empty sub-intervals of [min, max] satisfying the comparison.
+func (c comparison) intervals(minMax [2]int) [][2]int {
+	lo, hi := minMax[0], minMax[1]
+	v := c.value
+
+	var candidates [][2]int
+	switch c.operator {
+	case "<":
+		candidates = [][2]int{{lo, min(hi, v-1)}}
+	case "<=":
+		candidates = [][2]int{{lo, min(hi, v)}}
+	case ">":
+		candidates = [][2]int{{max(lo, v+1), hi}}
+	case ">=":
+		candidates = [][2]int{{max(lo, v), hi}}
+	case "==":
+		candidates = [][2]int{{max(lo, v), min(hi, v)}}
+	case "!=":
+		candidates = [][2]int{{lo, min(hi, v-1)}, {max(lo, v+1), hi}}
 	default:
 		panic("invalid input")
 	}
+
+	var out [][2]int
+	for _, i := range candidates {
+		if i[0] <= i[1] {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// split divides r into the ranges that satisfy the comparison and the ranges that don't.
+func (c comparison) split(r ranges) (pass, fail []ranges) {
+	minMax, ok := r[c.category]
+	if !ok {
+		panic("invalid input")
+	}
+
+	for _, i := range c.intervals(minMax) {
+		cp := copyMap(r)
+		cp[c.category] = i
+		pass = append(pass, cp)
+	}
+
+	negated := comparison{c.category, negations[c.operator], c.value}
+	for _, i := range negated.intervals(minMax) {
+		cp := copyMap(r)
+		cp[c.category] = i
+		fail = append(fail, cp)
+	}
+
+	return pass, fail
+}
+
+// condition is a disjunction (||) of conjunctions (&&) of comparisons. An empty condition always
+// matches, as with the standalone rule at the end of a workflow.
+type condition [][]comparison
+
+func (c condition) String() string {
+	var clauses []string
+	for _, clause := range c {
+		var cmps []string
+		for _, cmp := range clause {
+			cmps = append(cmps, cmp.String())
+		}
+		clauses = append(clauses, strings.Join(cmps, "&&"))
+	}
+	return strings.Join(clauses, "||")
+}
+
+func (c condition) eval(p part) bool {
+	if len(c) == 0 {
+		return true
+	}
+
+	for _, clause := range c {
+		if evalClause(clause, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func evalClause(clause []comparison, p part) bool {
+	for _, cmp := range clause {
+		if !cmp.eval(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// split divides r into disjoint ranges that satisfy the condition and ranges that don't.
+func (c condition) split(r ranges) (pass, fail []ranges) {
+	if len(c) == 0 {
+		return []ranges{r}, nil
+	}
+
+	// each clause only gets a chance at what earlier clauses rejected
+	fail = []ranges{r}
+	for _, clause := range c {
+		var rejected []ranges
+		for _, cur := range fail {
+			p, f := splitClause(clause, cur)
+			pass = append(pass, p...)
+			rejected = append(rejected, f...)
+		}
+		fail = rejected
+	}
+
+	return pass, fail
+}
+
+func splitClause(clause []comparison, r ranges) (pass, fail []ranges) {
+	// each comparison narrows what passed the previous ones
+	pass = []ranges{r}
+	for _, cmp := range clause {
+		var accepted []ranges
+		for _, cur := range pass {
+			p, f := cmp.split(cur)
+			accepted = append(accepted, p...)
+			fail = append(fail, f...)
+		}
+		pass = accepted
+	}
+
+	return pass, fail
 }
 
 type rule struct {
-	category rune // x, m, a, s
-	dest     string
-	operator rune
-	value    int
+	cond condition
+	dest string
 }
 
 func (r rule) String() string {
-	return fmt.Sprintf("%c: dest:%s", r.category, r.dest)
+	if len(r.cond) == 0 {
+		return r.dest
+	}
+	return fmt.Sprintf("%s:%s", r.cond, r.dest)
+}
+
+func parseCondition(raw string) condition {
+	var cond condition
+	for _, rawClause := range strings.Split(raw, "||") {
+		var clause []comparison
+		for _, rawCmp := range strings.Split(rawClause, "&&") {
+			matches := comparisonRE.FindStringSubmatch(rawCmp)
+			if matches == nil {
+				panic("invalid input")
+			}
+
+			category := matches[1] // x, m, a, s, ...
+			operator := matches[2] // <, <=, >, >=, == or !=
+			value, _ := strconv.Atoi(matches[3])
+
+			clause = append(clause, comparison{category, operator, value})
+		}
+		cond = append(cond, clause)
+	}
+
+	return cond
 }
 
 func parseRules(rawRules []string) []rule {
@@ -69,22 +269,7 @@ func parseRules(rawRules []string) []rule {
 			continue
 		}
 
-		matches := conditionRE.FindStringSubmatch(ruleParts[0])
-		dest := ruleParts[1]
-
-		left := matches[1]     // x, m, a, s
-		operator := matches[2] // < or >
-		right := matches[3]
-		value, _ := strconv.Atoi(right)
-
-		newRule := rule{
-			category: rune(left[0]),
-			dest:     dest,
-			value:    value,
-			operator: rune(operator[0]),
-		}
-
-		rules = append(rules, newRule)
+		rules = append(rules, rule{cond: parseCondition(ruleParts[0]), dest: ruleParts[1]})
 	}
 
 	return rules
@@ -110,19 +295,43 @@ func parseRatings(ratings []string) []part {
 
 	for _, line := range ratings {
 		match := ratingsRE.FindStringSubmatch(line)
+		if match == nil {
+			panic("invalid input")
+		}
 
-		x, _ := strconv.Atoi(match[1])
-		m, _ := strconv.Atoi(match[2])
-		a, _ := strconv.Atoi(match[3])
-		s, _ := strconv.Atoi(match[4])
+		p := make(part)
+		for _, raw := range strings.Split(match[1], ",") {
+			rating := ratingRE.FindStringSubmatch(raw)
+			if rating == nil {
+				panic("invalid input")
+			}
+			p[rating[1]], _ = strconv.Atoi(rating[2])
+		}
 
-		parts = append(parts, part{x, m, a, s})
+		parts = append(parts, p)
 	}
 
 	return parts
 }
 
-func process(flows map[string][]rule, flowName string, p part) bool {
+// categories returns the sorted set of category names declared across all parts.
+func categories(parts []part) []string {
+	var names []string
+	for _, p := range parts {
+		for name := range p {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// process reports whether p is accepted starting from flowName. If rt is non-nil, each workflow
+// visited and the rule that matched there is appended to it.
+func process(flows map[string][]rule, flowName string, p part, rt *route) bool {
 	// base case
 	if flowName == "A" {
 		return true
@@ -132,21 +341,19 @@ func process(flows map[string][]rule, flowName string, p part) bool {
 
 	rules := flows[flowName]
 
-	for _, rule := range rules {
-		switch rule.operator {
-		case '<':
-			if p.rating(rule.category) < rule.value {
-				return process(flows, rule.dest, p)
-			}
-		case '>':
-			if p.rating(rule.category) > rule.value {
-				return process(flows, rule.dest, p)
+	for i, rule := range rules {
+		if rule.cond.eval(p) {
+			if rt != nil {
+				*rt = append(*rt, step{workflow: flowName, rule: i, matched: rule})
 			}
-		default: // standalone rule
-			return process(flows, rule.dest, p)
+			return process(flows, rule.dest, p, rt)
 		}
 	}
 
+	// no rule matched, so the part is rejected
+	if rt != nil {
+		*rt = append(*rt, step{workflow: flowName, rule: -1, matched: rule{dest: "R"}})
+	}
 	return false
 }
 
@@ -157,7 +364,7 @@ func part1(workflows []string, ratings []string) int {
 
 	sum := 0
 	for _, p := range parts {
-		if process(flows, "in", p) {
+		if process(flows, "in", p, nil) {
 			sum += p.sum()
 		}
 	}
@@ -165,8 +372,8 @@ func part1(workflows []string, ratings []string) int {
 	return sum
 }
 
-func copyMap(in map[rune][2]int) map[rune][2]int {
-	cp := make(map[rune][2]int)
+func copyMap(in ranges) ranges {
+	cp := make(ranges, len(in))
 	for k, v := range in {
 		cp[k] = v
 	}
@@ -174,54 +381,86 @@ func copyMap(in map[rune][2]int) map[rune][2]int {
 	return cp
 }
 
-func processCombos(flows map[string][]rule, flowName string, ranges map[rune][2]int) int {
+func processCombos(flows map[string][]rule, flowName string, r ranges) int {
 	if flowName == "R" {
 		return 0
 	} else if flowName == "A" { // return the product of ranges
 		product := 1
-		for _, minMax := range ranges {
+		for _, minMax := range r {
 			product *= (minMax[1] - minMax[0] + 1)
 		}
 		return product
 	}
 
 	var total int
-	rules := flows[flowName]
-	for _, rule := range rules {
-
-		minMax := ranges[rule.category]
-		newRanges := copyMap(ranges)
-		newMinMax := minMax
-		switch rule.operator {
-		case '<':
-			newMinMax[1] = rule.value - 1 // set max to < value (successful range)
-			minMax[0] = rule.value        // set min to value (non-successful range)
-
-		case '>':
-			newMinMax[0] = rule.value + 1 // set min to > value (successful range)
-			minMax[1] = rule.value        //set max to value (non-successful range)
-
-		default: // standalone rule
-			total += processCombos(flows, rule.dest, ranges)
-			continue
+	remaining := []ranges{r}
+	for _, rule := range flows[flowName] {
+		var rejected []ranges
+		for _, cur := range remaining {
+			pass, fail := rule.cond.split(cur)
+			for _, p := range pass {
+				total += processCombos(flows, rule.dest, p) // (successful)
+			}
+			rejected = append(rejected, fail...) // (non-successful) - on to the next rule
 		}
-
-		ranges[rule.category] = minMax
-		newRanges[rule.category] = newMinMax
-		total += processCombos(flows, rule.dest, newRanges) // (successful)
+		remaining = rejected
 	}
 
 	return total
 }
 
+// universe returns the full range of ratings for every category declared by the parts.
+func universe(parts []part) ranges {
+	r := make(ranges)
+	for _, c := range categories(parts) {
+		r[c] = [2]int{minRating, maxRating}
+	}
+
+	return r
+}
+
 func part2(workflows []string, ratings []string) int {
 	flows := parseWorkflows(workflows)
-	categories := map[rune][2]int{'x': {1, 4000}, 'm': {1, 4000}, 'a': {1, 4000}, 's': {1, 4000}}
-	return processCombos(flows, "in", categories)
+	return processCombos(flows, "in", universe(parseRatings(ratings)))
 }
 
 func main() {
+	check := flag.Bool("check", false, "report workflow issues and exit")
+	routes := flag.Bool("routes", false, "print the workflow route each part takes and exit")
+	hits := flag.Bool("hits", false, "print how many parts matched each rule and exit")
+	simplified := flag.Bool("simplify", false, "print the workflows with uniform workflows collapsed and exit")
+	flag.Parse()
+
 	workflows, ratings := parseInput()
+	flows := parseWorkflows(workflows)
+
+	if *simplified {
+		fmt.Print(formatWorkflows(simplify(flows)))
+		return
+	}
+
+	fatal := false
+	for _, i := range validate(flows, universe(parseRatings(ratings))) {
+		if *check || i.fatal() {
+			fmt.Fprintln(os.Stderr, i)
+		}
+		fatal = fatal || i.fatal()
+	}
+	if *check {
+		return
+	}
+	if fatal {
+		os.Exit(1)
+	}
+
+	if *routes {
+		printRoutes(flows, ratings)
+		return
+	}
+	if *hits {
+		printHits(flows, ratings)
+		return
+	}
 
 	fmt.Println("Part 1:", part1(workflows, ratings))
 	fmt.Println("Part 2:", part2(workflows, ratings))
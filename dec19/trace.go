@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// step records the rule that matched while a part passed through a workflow.
+type step struct {
+	workflow string
+	rule     int // index of the matched rule within the workflow, or -1 if none matched
+	matched  rule
+}
+
+func (s step) String() string {
+	if s.rule < 0 {
+		return fmt.Sprintf("%s (no match)", s.workflow)
+	}
+	return fmt.Sprintf("%s (%s)", s.workflow, s.matched)
+}
+
+// route is the sequence of workflows a part passed through, ending in A or R. A part matching no
+// rule in a workflow ends with a step for that workflow with no matched rule, and is rejected.
+type route []step
+
+func (r route) dest() string {
+	if len(r) == 0 {
+		return ""
+	}
+	return r[len(r)-1].matched.dest
+}
+
+// String renders the route like "in (qqz) -> qqz (s>2770:qs) -> qs (lnx) -> lnx (m>1548:A) -> A".
+func (r route) String() string {
+	var steps []string
+	for _, s := range r {
+		steps = append(steps, s.String())
+	}
+	return strings.Join(append(steps, r.dest()), " -> ")
+}
+
+func trace(flows map[string][]rule, p part) route {
+	var rt route
+	process(flows, "in", p, &rt)
+	return rt
+}
+
+func printRoutes(flows map[string][]rule, ratings []string) {
+	for i, p := range parseRatings(ratings) {
+		fmt.Printf("%s: %s\n", ratings[i], trace(flows, p))
+	}
+}
+
+// printHits prints, for every rule of every workflow, how many parts matched it, along with how
+// many parts matched no rule at all in workflows that can be fallen through.
+func printHits(flows map[string][]rule, ratings []string) {
+	counts := make(map[string][]int, len(flows))
+	unmatched := make(map[string]int)
+	for name, rules := range flows {
+		counts[name] = make([]int, len(rules))
+	}
+
+	for _, p := range parseRatings(ratings) {
+		for _, s := range trace(flows, p) {
+			if s.rule < 0 {
+				unmatched[s.workflow]++
+				continue
+			}
+			counts[s.workflow][s.rule]++
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, name := range flowNames(flows) {
+		rules := flows[name]
+		for i, r := range rules {
+			fmt.Fprintf(w, "%s[%d]\t%s\t%d\n", name, i+1, r, counts[name][i])
+		}
+		if len(rules) == 0 || len(rules[len(rules)-1].cond) > 0 {
+			fmt.Fprintf(w, "%s[-]\tno match:R\t%d\n", name, unmatched[name])
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type strategy int
+
+const (
+	quadratic strategy = iota
+	extrapolated
+	exhaustive
+)
+
+func (s strategy) String() string {
+	switch s {
+	case quadratic:
+		return "quadratic fit"
+	case extrapolated:
+		return "extrapolated tile distances"
+	case exhaustive:
+		return "exhaustive search"
+	}
+	return ""
+}
+
+// distanceMap holds the fewest steps from the start to every plot in a block of tiled copies of
+// the grid, reaching radius copies out from the original in every direction. A radius of 0 is
+// just the original, finite grid.
+type distanceMap struct {
+	w, h   int // size of a single copy of the grid
+	radius int
+	dist   []int32 // -1 where a plot can't be reached (or is a rock)
+
+	// within[p][d] is the number of plots at most d steps away whose distance has parity p
+	within [2][]int
+}
+
+// newDistanceMap runs one BFS from start across the block of tiled copies.
+func newDistanceMap(grid []string, start point, radius int) distanceMap {
+	w, h := len(grid[0]), len(grid)
+	m := distanceMap{w: w, h: h, radius: radius}
+
+	width, height := (2*radius+1)*w, (2*radius+1)*h
+	m.dist = make([]int32, width*height)
+	for i := range m.dist {
+		m.dist[i] = -1
+	}
+
+	origin := point{radius*w + start.x, radius*h + start.y}
+	m.dist[origin.y*width+origin.x] = 0
+	queue := []point{origin}
+	furthest := 0
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		x, y := cur.x, cur.y
+		choices := []point{
+			{x: x, y: y - 1}, // up
+			{x: x, y: y + 1}, // down
+			{x: x - 1, y: y}, // left
+			{x: x + 1, y: y}, // right
+		}
+
+		for _, p := range choices {
+			if p.x < 0 || p.x >= width || p.y < 0 || p.y >= height {
+				continue
+			}
+			if grid[p.y%h][p.x%w] == '#' || m.dist[p.y*width+p.x] >= 0 {
+				continue
+			}
+
+			m.dist[p.y*width+p.x] = m.dist[y*width+x] + 1
+			furthest = int(m.dist[p.y*width+p.x]) // BFS reaches plots in distance order
+			queue = append(queue, p)
+		}
+	}
+
+	histogram := make([]int, furthest+1)
+	for _, d := range m.dist {
+		if d >= 0 {
+			histogram[d]++
+		}
+	}
+
+	for p := range m.within {
+		m.within[p] = make([]int, furthest+1)
+		total := 0
+		for d, n := range histogram {
+			if d%2 == p {
+				total += n
+			}
+			m.within[p][d] = total
+		}
+	}
+
+	return m
+}
+
+// at returns the distance to cell within the copy of the grid at tile, where tile (0,0) is the
+// original.
+func (m distanceMap) at(tile, cell point) int {
+	width := (2*m.radius + 1) * m.w
+	x := (tile.x+m.radius)*m.w + cell.x
+	y := (tile.y+m.radius)*m.h + cell.y
+
+	return int(m.dist[y*width+x])
+}
+
+// reachable returns, for each step count, how many plots within the map can be reached in
+// exactly that many steps. A plot reached in fewer steps can be reached again by stepping away and
+// back, so long as the parity matches.
+func (m distanceMap) reachable(steps ...int) []int {
+	counts := make([]int, len(steps))
+	for i, s := range steps {
+		if s < 0 {
+			continue
+		}
+
+		within := m.within[s%2]
+		counts[i] = within[min(s, len(within)-1)]
+	}
+
+	return counts
+}
+
+func (m distanceMap) count(steps int) int {
+	return m.reachable(steps)[0]
+}
+
+// quadraticAssumptions lists why the quadratic fit can't be used for steps: it relies on a square
+// grid with the start in the exact center, a clear start row and column so the reachable area
+// grows as a diamond, and a step count landing exactly on the edge of a copy of the grid.
+func quadraticAssumptions(grid []string, start point, steps int) []string {
+	var broken []string
+
+	n := len(grid)
+	for _, row := range grid {
+		if len(row) != n {
+			broken = append(broken, "grid is not square")
+			return broken
+		}
+	}
+
+	if n%2 == 0 || start.x != n/2 || start.y != n/2 {
+		broken = append(broken, "start is not in the center of the grid")
+	}
+
+	if strings.Contains(grid[start.y], "#") {
+		broken = append(broken, "start row has rocks")
+	}
+	for y := range grid {
+		if grid[y][start.x] == '#' {
+			broken = append(broken, "start column has rocks")
+			break
+		}
+	}
+
+	if steps%n != start.y {
+		broken = append(broken, fmt.Sprintf("%d steps does not end on the edge of a copy of the grid", steps))
+	}
+
+	return broken
+}
+
+// solveQuadratic counts plots for steps = start.y + x*n by fitting a quadratic in x to the counts
+// for the first three copies of the grid.
+func solveQuadratic(grid []string, start point, steps int) int {
+	n := len(grid)
+	m := newDistanceMap(grid, start, 3)
+
+	samples := m.reachable(start.y, start.y+n, start.y+n*2)
+	s1, s2, s3 := samples[0], samples[1], samples[2]
+
+	// quadratic - x is the number of repeated grids in x direction
+	// ax^2 + bx + c
+	x := steps / n
+
+	// ((diff between s2 and s3) - (diff between s1 and s2)) / 2
+	// (s3-s2)-(s2-s1)
+	a := (s3 - 2*s2 + s1) / 2
+	b := s2 - s1 - a
+	c := s1
+
+	return (a*x*x + b*x + c)
+}
+
+const (
+	minRadius = 4
+	maxRadius = 16
+
+	// largest number of plots an exhaustive search will explore
+	maxExhaustive = 50_000_000
+)
+
+// reachable counts the garden plots reachable in exactly steps across the infinite garden,
+// reporting the strategy it used. The quadratic fit is used when its assumptions hold; otherwise
+// distances across tiled copies are extrapolated, or searched exhaustively if that fails and the
+// step count is small enough.
+func reachable(grid []string, start point, steps int, general bool) (int, strategy, error) {
+	if !general && len(quadraticAssumptions(grid, start, steps)) == 0 {
+		return solveQuadratic(grid, start, steps), quadratic, nil
+	}
+
+	for radius := minRadius; radius <= maxRadius; radius *= 2 {
+		if total, ok := newDistanceMap(grid, start, radius).extrapolate(steps); ok {
+			return total, extrapolated, nil
+		}
+	}
+
+	w, h := len(grid[0]), len(grid)
+	radius := steps/min(w, h) + 1
+	if side := (2*radius + 1); side*side*w*h <= maxExhaustive {
+		return newDistanceMap(grid, start, radius).count(steps), exhaustive, nil
+	}
+
+	return 0, exhaustive, errors.New("distances across tiled copies never settled into a pattern, and too many steps to search exhaustively")
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// extrapolate counts plots reachable in exactly steps across the infinite garden. It assumes that
+// beyond a core of copies around the original, each further copy in a direction adds a fixed
+// number of steps to reach every cell, checks that assumption against the outer copies of the
+// map, and reports false if it doesn't hold.
+func (m distanceMap) extrapolate(steps int) (int, bool) {
+	core := m.radius / 2
+
+	// steps added to reach a cell by moving one more copy along each axis, beyond the core
+	axes := []point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	deltas := make(map[point][]int, len(axes))
+	for _, u := range axes {
+		deltas[u] = make([]int, m.w*m.h)
+		for y := 0; y < m.h; y++ {
+			for x := 0; x < m.w; x++ {
+				cell := point{x, y}
+				edge := point{u.x * core, u.y * core}
+				delta := m.at(point{edge.x + u.x, edge.y + u.y}, cell) - m.at(edge, cell)
+				if m.at(edge, cell) >= 0 && delta <= 0 {
+					return 0, false
+				}
+				deltas[u][y*m.w+x] = delta
+			}
+		}
+	}
+
+	// predict returns the extrapolated distance to cell in a copy outside the core
+	predict := func(tile, cell point) int {
+		from := point{sign(tile.x) * min(abs(tile.x), core), sign(tile.y) * min(abs(tile.y), core)}
+		d := m.at(from, cell)
+		if d < 0 {
+			return d
+		}
+
+		i := cellIndex(cell, m.w)
+		if extra := abs(tile.x) - core; extra > 0 {
+			d += extra * deltas[point{sign(tile.x), 0}][i]
+		}
+		if extra := abs(tile.y) - core; extra > 0 {
+			d += extra * deltas[point{0, sign(tile.y)}][i]
+		}
+		return d
+	}
+
+	// check the pattern holds for every copy in the map outside the core
+	for ty := -m.radius; ty <= m.radius; ty++ {
+		for tx := -m.radius; tx <= m.radius; tx++ {
+			tile := point{tx, ty}
+			if max(abs(tx), abs(ty)) <= core {
+				continue
+			}
+
+			for y := 0; y < m.h; y++ {
+				for x := 0; x < m.w; x++ {
+					cell := point{x, y}
+					if predict(tile, cell) != m.at(tile, cell) {
+						return 0, false
+					}
+				}
+			}
+		}
+	}
+
+	total := 0
+	for ty := -core; ty <= core; ty++ {
+		for tx := -core; tx <= core; tx++ {
+			tile := point{tx, ty}
+			beyondX, beyondY := abs(tx) == core, abs(ty) == core
+
+			for y := 0; y < m.h; y++ {
+				for x := 0; x < m.w; x++ {
+					cell := point{x, y}
+					d := m.at(tile, cell)
+					if d < 0 {
+						continue
+					}
+
+					// the core copy itself
+					if d <= steps && (steps-d)%2 == 0 {
+						total++
+					}
+
+					i := cellIndex(cell, m.w)
+					switch {
+					case beyondX && beyondY: // every copy diagonally out from a core corner
+						dx := deltas[point{sign(tx), 0}][i]
+						dy := deltas[point{0, sign(ty)}][i]
+						total += countQuadrant(d, dx, dy, steps)
+					case beyondX: // every copy further out along the row
+						total += countLine(d, deltas[point{sign(tx), 0}][i], steps, 1)
+					case beyondY: // every copy further out along the column
+						total += countLine(d, deltas[point{0, sign(ty)}][i], steps, 1)
+					}
+				}
+			}
+		}
+	}
+
+	return total, true
+}
+
+// cellIndex returns the index of cell within a single copy of the grid.
+func cellIndex(cell point, w int) int {
+	return cell.y*w + cell.x
+}
+
+// terms returns the first and last k >= from, and the stride between them, for which
+// d + k*delta <= steps with the same parity as steps. ok is false if there are none.
+func terms(d, delta, steps, from int) (first, last, stride int, ok bool) {
+	if delta <= 0 || d+from*delta > steps {
+		return 0, 0, 0, false
+	}
+
+	last = (steps - d) / delta
+	first, stride = from, 1
+	if delta%2 == 0 {
+		if (steps-d)%2 != 0 {
+			return 0, 0, 0, false
+		}
+	} else {
+		stride = 2
+		if (steps-d-first*delta)%2 != 0 {
+			first++
+		}
+	}
+
+	return first, last, stride, first <= last
+}
+
+// countLine returns how many copies k >= from further along a line, each delta more steps away,
+// reach the cell in exactly steps.
+func countLine(d, delta, steps, from int) int {
+	first, last, stride, ok := terms(d, delta, steps, from)
+	if !ok {
+		return 0
+	}
+
+	return (last-first)/stride + 1
+}
+
+// countQuadrant returns how many copies i across and j down from a core corner (excluding the
+// corner itself), each dx and dy more steps away respectively, reach the cell in exactly steps.
+func countQuadrant(d, dx, dy, steps int) int {
+	if dx == dy {
+		// the k+1 copies with i+j == k are all the same distance away
+		first, last, stride, ok := terms(d, dx, steps, 1)
+		if !ok {
+			return 0
+		}
+
+		n := (last-first)/stride + 1
+		return n*(first+1) + stride*n*(n-1)/2
+	}
+
+	total := 0
+	for i := 0; d+i*dx <= steps; i++ {
+		from := 0
+		if i == 0 {
+			from = 1
+		}
+		total += countLine(d+i*dx, dy, steps, from)
+	}
+
+	return total
+}
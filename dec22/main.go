@@ -1,313 +1,112 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"slices"
-	"strconv"
 	"strings"
 )
 
-type grid [][][]int
+// grid records which brick occupies each cube. Only occupied cubes are stored, and the bounds
+// track the extent of the bricks along each axis separately.
+type grid struct {
+	cells    map[[3]int]int
+	min, max [3]int // inclusive bounds of every brick
+}
 
 type brick struct {
 	id    int
-	start [3]int
-	end   [3]int
+	start [3]int // lowest corner
+	end   [3]int // highest corner
+}
+
+func newGrid() grid {
+	return grid{cells: make(map[[3]int]int)}
 }
 
 func parseInput() (grid, []brick) {
-	raw, _ := os.ReadFile(os.Args[1])
+	raw, _ := os.ReadFile(flag.Arg(0))
 	lines := strings.Split(strings.Trim(string(raw), "\n"), "\n")
 
-	max := 0
-
 	var bricks []brick
 	for i, l := range lines {
-		parts := strings.Split(l, "~")
-		startRaw := strings.Split(parts[0], ",")
-		endRaw := strings.Split(parts[1], ",")
-		sx, _ := strconv.Atoi(startRaw[0])
-		sy, _ := strconv.Atoi(startRaw[1])
-		sz, _ := strconv.Atoi(startRaw[2])
-		ex, _ := strconv.Atoi(endRaw[0])
-		ey, _ := strconv.Atoi(endRaw[1])
-		ez, _ := strconv.Atoi(endRaw[2])
-
-		start := [3]int{sx, sy, sz}
-		end := [3]int{ex, ey, ez}
-
-		bricks = append(bricks, brick{i, start, end})
-
-		if ex > max {
-			max = sx
-		}
-		if ey > max {
-			max = ey
-		}
-		if ez > max {
-			max = ez
-		}
+		b, _ := parseBrick(l)
+		b.id = i
+		bricks = append(bricks, b)
 	}
 
-	grid := make(grid, max+1)
-	for x := range grid {
-		grid[x] = make([][]int, max+1)
-		for y := range grid[x] {
-			grid[x][y] = make([]int, max+1)
-			for z := range grid[x][y] {
-				grid[x][y][z] = -1
-			}
-		}
-	}
+	grid := newGrid()
 	grid.fillBricks(bricks)
 	return grid, bricks
 }
 
-func (g grid) displayXZ() {
-	fmt.Println("  x  ")
-
-	n := len(g)
-
-	for z := range g {
-		for x := range g {
-			// for each y layer, find the first brick if any
-			v := -1
-			for y := range g {
-				if g[x][y][n-1-z] >= 0 {
-					v = g[x][y][n-1-z]
-					break
-				}
-			}
-			if v == -1 {
-				fmt.Printf(".")
-			} else {
-				fmt.Printf("%c", 'A'+v)
-			}
-		}
-		fmt.Println(" ", n-1-z)
-	}
-}
-
-func (g grid) displayYZ() {
-	fmt.Println("  y  ")
-
-	n := len(g)
-
-	for z := range g {
-		for y := range g {
-			// for each x layer, find the first brick if any
-			v := -1
-			for x := range g {
-				if g[x][y][n-1-z] >= 0 {
-					v = g[x][y][n-1-z]
-					break
-				}
-			}
-			if v == -1 {
-				fmt.Printf(".")
-			} else {
-				fmt.Printf("%c", 'A'+v)
-			}
-		}
-		fmt.Println(" ", n-1-z)
-	}
-}
-
-func (g grid) fillBrick(b brick, id int) {
-	sx, ex := b.start[0], b.end[0]
-	sy, ey := b.start[1], b.end[1]
-	sz, ez := b.start[2], b.end[2]
-
-	dx := ex - sx
-	dy := ey - sy
-	dz := ez - sz
-
-	g[sx][sy][sz] = id
-
-	for d := 1; d <= dx; d++ {
-		g[sx+d][sy][sz] = id
+// parseBrick parses a brick like "1,0,1~1,2,1", ordering each axis so start is the lowest corner.
+func parseBrick(raw string) (brick, error) {
+	var b brick
+	var s, e [3]int
+	if _, err := fmt.Sscanf(raw, "%d,%d,%d~%d,%d,%d", &s[0], &s[1], &s[2], &e[0], &e[1], &e[2]); err != nil {
+		return b, fmt.Errorf("parsing brick %q: %w", raw, err)
 	}
-	for d := 1; d <= dy; d++ {
-		g[sx][sy+d][sz] = id
-	}
-	for d := 1; d <= dz; d++ {
-		g[sx][sy][sz+d] = id
-	}
-
-}
 
-func (g grid) fillBricks(bricks []brick) {
-	for i, b := range bricks {
-		g.fillBrick(b, i)
+	for axis := range b.start {
+		b.start[axis], b.end[axis] = min(s[axis], e[axis]), max(s[axis], e[axis])
 	}
+	return b, nil
 }
 
-func (g grid) removeBrick(b brick) {
-	sx, ex := b.start[0], b.end[0]
-	sy, ey := b.start[1], b.end[1]
-	sz, ez := b.start[2], b.end[2]
-
-	dx := ex - sx
-	dy := ey - sy
-	dz := ez - sz
-
-	g[sx][sy][sz] = -1
-
-	for d := 1; d <= dx; d++ {
-		g[sx+d][sy][sz] = -1
-	}
-	for d := 1; d <= dy; d++ {
-		g[sx][sy+d][sz] = -1
-	}
-	for d := 1; d <= dz; d++ {
-		g[sx][sy][sz+d] = -1
+// at returns the id of the brick occupying the cube at x,y,z, or -1 if it is empty.
+func (g grid) at(x, y, z int) int {
+	if id, ok := g.cells[[3]int{x, y, z}]; ok {
+		return id
 	}
+	return -1
 }
 
-func (g grid) canFall(b brick) bool {
-	sx, sy, sz := b.start[0], b.start[1], b.start[2]
-	ex, ey := b.end[0], b.end[1]
-
-	dx := ex - sx
-	dy := ey - sy
-
-	if sz == 1 { // on ground
-		return false
-	}
-
-	if g[sx][sy][sz-1] > -1 { // another brick below
-		return false
-	}
-
-	for d := 1; d <= dx; d++ { // need to check z-1 on all x
-		if g[sx+d][sy][sz-1] > -1 {
-			return false
-		}
+func (g *grid) fillBrick(b brick, id int) {
+	if len(g.cells) == 0 {
+		g.min, g.max = b.start, b.end
 	}
-	for d := 1; d <= dy; d++ { // need to check z-1 on all y
-		if g[sx][sy+d][sz-1] > -1 {
-			return false
-		}
+	for axis := range g.min {
+		g.min[axis] = min(g.min[axis], b.start[axis])
+		g.max[axis] = max(g.max[axis], b.end[axis])
 	}
 
-	return true
-
-}
-
-// fall returns a set of the bricks that fell
-func (g grid) fall(bricks []brick) {
-
-	for {
-		done := true
-		slices.SortFunc[[]brick](bricks, func(a, b brick) int {
-			return a.start[2] - b.start[2]
-		})
-
-		for i, b := range bricks {
-			g.removeBrick(b)
-			sx, sy, sz := b.start[0], b.start[1], b.start[2]
-			ex, ey := b.end[0], b.end[1]
-
-			if sz > 1 {
-				var stop bool
-				for j := 0; j < i; j++ { // for each brick below i
-					stop = false
-					bb := bricks[j]
-					bsx, bsy := bb.start[0], bb.start[1]
-					bex, bey, bez := bb.end[0], bb.end[1], bb.end[2]
-					if bez == sz-1 { // if brick is just below
-						if sx <= bex && ex >= bsx && sy <= bey && ey >= bsy {
-							stop = true
-							break
-						}
-					}
-				}
-				if !stop {
-					done = false
-					bricks[i].start[2]--
-					bricks[i].end[2]--
-				}
+	for x := b.start[0]; x <= b.end[0]; x++ {
+		for y := b.start[1]; y <= b.end[1]; y++ {
+			for z := b.start[2]; z <= b.end[2]; z++ {
+				g.cells[[3]int{x, y, z}] = id
 			}
 		}
-		if done {
-			break
-		}
 	}
-	g.fillBricks(bricks)
 }
 
-func fall(bricks []brick) {
-
-	for {
-		done := true
-		slices.SortFunc[[]brick](bricks, func(a, b brick) int {
-			return a.start[2] - b.start[2]
-		})
-
-		for i, b := range bricks {
-			sx, sy, sz := b.start[0], b.start[1], b.start[2]
-			ex, ey := b.end[0], b.end[1]
-
-			if sz > 1 {
-				var stop bool
-				for j := 0; j < i; j++ { // for each brick below i
-					stop = false
-					bb := bricks[j]
-					bsx, bsy := bb.start[0], bb.start[1]
-					bex, bey, bez := bb.end[0], bb.end[1], bb.end[2]
-					if bez == sz-1 { // if brick is just below
-						if sx <= bex && ex >= bsx && sy <= bey && ey >= bsy {
-							stop = true
-							break
-						}
-					}
-				}
-				if !stop {
-					done = false
-					bricks[i].start[2]--
-					bricks[i].end[2]--
-				}
+// clearBrick empties the cubes filled by b. The bounds are left as they were, so views of a
+// changing stack keep the same frame.
+func (g *grid) clearBrick(b brick) {
+	for x := b.start[0]; x <= b.end[0]; x++ {
+		for y := b.start[1]; y <= b.end[1]; y++ {
+			for z := b.start[2]; z <= b.end[2]; z++ {
+				delete(g.cells, [3]int{x, y, z})
 			}
 		}
-		if done {
-			break
-		}
 	}
 }
 
-func (g grid) disintegrate(idx int, bricks []brick) int {
-	brick := bricks[idx]
-	g.removeBrick(brick) // remove b temporarily
-
-	result := 0
-
+func (g *grid) fillBricks(bricks []brick) {
 	for i, b := range bricks {
-		if i == idx { // skip the removed brick
-			continue
-		}
-
-		if g.canFall(b) {
-			result++
-		}
+		g.fillBrick(b, i)
 	}
-
-	g.fillBrick(brick, idx) // put brick back
-	return result
 }
 
 func part1() int {
-	grid, bricks := parseInput()
-	// grid.displayXZ()
-	// fmt.Println()
-	// grid.displayYZ()
-
-	grid.fall(bricks)
+	_, bricks := parseInput()
+	s := settle(bricks)
 
 	sum := 0
 	for i := range bricks {
-		n := grid.disintegrate(i, bricks)
-		if n == 0 {
+		if s.safe(i) {
 			sum++
 		}
 	}
@@ -317,51 +116,162 @@ func part1() int {
 
 func part2() int {
 	_, bricks := parseInput()
-	fall(bricks)
+	s := settle(bricks)
 
 	total := 0
 	for i := range bricks {
-		copiedBricks := make([]brick, len(bricks))
-		copy(copiedBricks, bricks)
-		copiedBricks = append(copiedBricks[:i], copiedBricks[i+1:]...)
-		fallBricks := make([]brick, len(copiedBricks))
-		copy(fallBricks, copiedBricks)
-		fall(fallBricks)
-
-		for j := range copiedBricks {
-			for k := range fallBricks {
-				if copiedBricks[j].id == fallBricks[k].id {
-					if copiedBricks[j].start != fallBricks[k].start || copiedBricks[j].end != fallBricks[k].end {
-						total++
-					}
-					break
-				}
-			}
-		}
+		total += s.falls[i]
 	}
 
 	return total
 }
 
-func copyGrid(g grid) grid {
+// show prints or exports the view given by spec of the stack before and after settling,
+// highlighting what falls if the brick labelled remove is disintegrated.
+func show(spec, remove, svg string) error {
+	before, bricks := parseInput()
+	s := settle(bricks)
+	after := newGrid()
+	after.fillBricks(s.bricks)
+
+	h := highlight{removed: -1}
+	if remove != "" {
+		id, err := parseLabel(remove)
+		if err != nil {
+			return err
+		}
+		if id < 0 || id >= len(bricks) {
+			return fmt.Errorf("no brick %s", remove)
+		}
+		h = highlight{removed: id, falling: s.fallers(id)}
+	}
+
+	var views []view
+	for _, stage := range []struct {
+		g     grid
+		title string
+	}{{before, "before settling"}, {after, "after settling"}} {
+		v, err := stage.g.newView(spec, stage.title)
+		if err != nil {
+			return err
+		}
+		views = append(views, v)
+	}
+
+	if svg == "" {
+		for _, v := range views {
+			fmt.Println(v.ascii(h))
+		}
+		return nil
+	}
+
+	f, err := os.Create(svg)
+	if err != nil {
+		return err
+	}
+	if err := writeSVG(f, views, h); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+const exploreHelp = `commands:
+  remove LABEL       disintegrate a brick and let the bricks above it settle
+  add x,y,z~x,y,z    drop a new brick into the stack
+  view SPEC          show the stack as xz, yz, x=N, y=N or z=N
+  quit`
 
-	n := len(g)
-	c := make(grid, n)
+// explore settles the stack and then reads what-if commands from in, one per line, reporting every
+// brick that moves as the stack changes.
+func explore(in io.Reader) error {
+	_, bricks := parseInput()
+	moved := 0
+	s, err := newStack(bricks, func(from, to brick) {
+		moved++
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d bricks settled, %d of them fell\n", len(bricks), moved)
+	fmt.Println(exploreHelp)
 
-	for x := range c {
-		c[x] = make([][]int, n)
-		for y := range c[x] {
-			c[x][y] = make([]int, n)
-			for z := range c[x][y] {
-				c[x][y][z] = g[x][y][z]
+	s.onMove = func(from, to brick) {
+		fmt.Printf("  %s falls from z=%d to z=%d\n", label(to.id), from.start[2], to.start[2])
+	}
+
+	scanner := bufio.NewScanner(in)
+	for fmt.Print("> "); scanner.Scan(); fmt.Print("> ") {
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		arg = strings.TrimSpace(arg)
+
+		switch cmd {
+		case "":
+		case "remove":
+			id, err := parseLabel(arg)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fell, err := s.remove(id)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Printf("removed %s, %d bricks fell\n", label(id), len(fell))
+		case "add":
+			b, err := parseBrick(arg)
+			if err != nil {
+				fmt.Println(err)
+				continue
 			}
+			id, err := s.add(b)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Printf("added %s at z=%d\n", label(id), s.bricks[id].start[2])
+		case "view":
+			v, err := s.cells.newView(arg, "current stack")
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Println(v.ascii(highlight{removed: -1}))
+		case "quit":
+			return nil
+		default:
+			fmt.Println(exploreHelp)
 		}
 	}
+	fmt.Println()
 
-	return c
+	return scanner.Err()
 }
 
 func main() {
+	spec := flag.String("view", "", "show the stack before and after settling: xz or yz to project along an axis, or x=N, y=N or z=N to slice through a plane")
+	remove := flag.String("remove", "", "with -view, highlight the bricks that fall if the brick with this `label` is disintegrated")
+	svg := flag.String("svg", "", "with -view, write the views to an SVG `file` instead of printing them")
+	interactive := flag.Bool("explore", false, "settle the stack, then read commands from stdin to add and remove bricks and watch what falls")
+	flag.Parse()
+
+	if *interactive {
+		if err := explore(os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *spec != "" {
+		if err := show(*spec, *remove, *svg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Println("Part 1:", part1())
 	fmt.Println("Part 2:", part2())
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// stack is a settled pile of bricks that can be changed one brick at a time. Adding or removing a
+// brick only re-settles the bricks that could be affected, rather than the whole pile.
+type stack struct {
+	cells  grid
+	bricks map[int]brick // settled bricks by id
+	nextID int
+
+	// onMove, if set, is called for every brick that drops, with its old and new positions
+	onMove func(from, to brick)
+}
+
+// newStack settles bricks into a new stack, lowest first, reporting every brick that drops to
+// onMove.
+func newStack(bricks []brick, onMove func(from, to brick)) (*stack, error) {
+	s := &stack{cells: newGrid(), bricks: make(map[int]brick), onMove: onMove}
+
+	sorted := slices.Clone(bricks)
+	slices.SortStableFunc(sorted, func(a, b brick) int {
+		return a.start[2] - b.start[2]
+	})
+
+	for _, b := range sorted {
+		if err := s.place(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// add drops a new brick into the stack from where it is given, returning its id. Nothing rests on
+// a brick until it has landed, so no other brick moves.
+func (s *stack) add(b brick) (int, error) {
+	b.id = s.nextID
+	if err := s.place(b); err != nil {
+		return 0, err
+	}
+	return b.id, nil
+}
+
+func (s *stack) place(b brick) error {
+	if b.start[2] < 1 {
+		return fmt.Errorf("brick %s is below the ground", label(b.id))
+	}
+	if ids := s.occupants(b); len(ids) > 0 {
+		return fmt.Errorf("brick %s overlaps brick %s", label(b.id), label(ids[0]))
+	}
+
+	s.cells.fillBrick(b, b.id)
+	s.bricks[b.id] = b
+	s.nextID = max(s.nextID, b.id+1)
+	s.drop(b.id)
+	return nil
+}
+
+// remove takes brick id out of the stack and re-settles the bricks above it, returning the ids
+// of those that fell. Only bricks resting on something that moved are checked, lowest first so
+// every brick is checked after everything it might rest on has settled.
+func (s *stack) remove(id int) ([]int, error) {
+	b, ok := s.bricks[id]
+	if !ok {
+		return nil, fmt.Errorf("no brick %s", label(id))
+	}
+
+	s.cells.clearBrick(b)
+	delete(s.bricks, id)
+
+	var fell []int
+	pending := s.resting(b)
+	for len(pending) > 0 {
+		lowest := 0
+		for i, p := range pending {
+			if s.bricks[p].start[2] < s.bricks[pending[lowest]].start[2] {
+				lowest = i
+			}
+		}
+		cur := pending[lowest]
+		pending = slices.Delete(pending, lowest, lowest+1)
+
+		from := s.bricks[cur]
+		if !s.drop(cur) {
+			continue
+		}
+		if !slices.Contains(fell, cur) {
+			fell = append(fell, cur)
+		}
+
+		for _, a := range s.resting(from) {
+			if !slices.Contains(pending, a) {
+				pending = append(pending, a)
+			}
+		}
+	}
+
+	return fell, nil
+}
+
+// drop lowers brick id as far as it will go, reporting whether it moved.
+func (s *stack) drop(id int) bool {
+	from := s.bricks[id]
+	to := from
+	for to.start[2] > 1 && s.clearBelow(to) {
+		to.start[2]--
+		to.end[2]--
+	}
+	if to == from {
+		return false
+	}
+
+	s.cells.clearBrick(from)
+	s.cells.fillBrick(to, id)
+	s.bricks[id] = to
+	if s.onMove != nil {
+		s.onMove(from, to)
+	}
+	return true
+}
+
+// clearBelow reports whether every cube directly under the brick is empty.
+func (s *stack) clearBelow(b brick) bool {
+	for _, c := range b.footprint() {
+		if s.cells.at(c[0], c[1], b.start[2]-1) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// resting returns the bricks sitting directly on top of b.
+func (s *stack) resting(b brick) []int {
+	var ids []int
+	for _, c := range b.footprint() {
+		if id := s.cells.at(c[0], c[1], b.end[2]+1); id >= 0 && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// occupants returns the bricks already filling any cube of b.
+func (s *stack) occupants(b brick) []int {
+	var ids []int
+	for x := b.start[0]; x <= b.end[0]; x++ {
+		for y := b.start[1]; y <= b.end[1]; y++ {
+			for z := b.start[2]; z <= b.end[2]; z++ {
+				if id := s.cells.at(x, y, z); id >= 0 && !slices.Contains(ids, id) {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
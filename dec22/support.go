@@ -0,0 +1,157 @@
+package main
+
+import "slices"
+
+// support is the settled stack as a graph of which bricks rest on which.
+type support struct {
+	bricks []brick // settled positions, indexed by brick id
+	below  [][]int // below[i] lists the bricks i rests directly on, empty if on the ground
+	above  [][]int // above[i] lists the bricks resting directly on i
+	order  []int   // brick ids from the ground up, so every brick comes after those it rests on
+	idom   []int   // immediate dominator of each brick, or ground
+	falls  []int   // falls[i] is how many other bricks fall if i is disintegrated
+}
+
+// ground stands in for the floor as the root of the dominator tree.
+const ground = -1
+
+// settle drops every brick as far as it will go, lowest first, tracking the highest brick over
+// each x,y column to find where each lands and what it lands on.
+func settle(bricks []brick) support {
+	s := support{
+		bricks: slices.Clone(bricks),
+		below:  make([][]int, len(bricks)),
+		above:  make([][]int, len(bricks)),
+	}
+
+	for i := range s.bricks {
+		s.order = append(s.order, i)
+	}
+	slices.SortStableFunc(s.order, func(a, b int) int {
+		return s.bricks[a].start[2] - s.bricks[b].start[2]
+	})
+
+	type top struct {
+		z, id int
+	}
+	tops := make(map[[2]int]top)
+
+	for _, id := range s.order {
+		b := &s.bricks[id]
+
+		rest := 0 // highest z under the brick's footprint, 0 being the ground
+		for _, c := range b.footprint() {
+			if t, ok := tops[c]; ok {
+				rest = max(rest, t.z)
+			}
+		}
+
+		height := b.end[2] - b.start[2]
+		b.start[2], b.end[2] = rest+1, rest+1+height
+
+		for _, c := range b.footprint() {
+			if t, ok := tops[c]; ok && t.z == rest && !slices.Contains(s.below[id], t.id) {
+				s.below[id] = append(s.below[id], t.id)
+				s.above[t.id] = append(s.above[t.id], id)
+			}
+			tops[c] = top{b.end[2], id}
+		}
+	}
+
+	s.dominators()
+	return s
+}
+
+// footprint returns the x,y columns the brick occupies.
+func (b brick) footprint() [][2]int {
+	var cells [][2]int
+	for x := b.start[0]; x <= b.end[0]; x++ {
+		for y := b.start[1]; y <= b.end[1]; y++ {
+			cells = append(cells, [2]int{x, y})
+		}
+	}
+
+	return cells
+}
+
+// dominators builds the dominator tree of the support graph rooted at the ground: brick d
+// dominates brick i if every chain of supports from the ground to i passes through d, so
+// disintegrating d brings down exactly the bricks it dominates.
+func (s *support) dominators() {
+	n := len(s.bricks)
+	s.idom = make([]int, n)
+	depth := make([]int, n)
+
+	depthOf := func(id int) int {
+		if id == ground {
+			return 0
+		}
+		return depth[id]
+	}
+
+	// lca finds the closest common dominator of a and b by walking up the tree
+	lca := func(a, b int) int {
+		for a != b {
+			if depthOf(a) < depthOf(b) {
+				a, b = b, a
+			}
+			a = s.idom[a]
+		}
+		return a
+	}
+
+	// the graph is acyclic and order is topological, so every brick's supports already have
+	// their dominators when it is reached
+	for _, id := range s.order {
+		dom := ground
+		for i, b := range s.below[id] {
+			if i == 0 {
+				dom = b
+			} else {
+				dom = lca(dom, b)
+			}
+		}
+
+		s.idom[id] = dom
+		depth[id] = depthOf(dom) + 1
+	}
+
+	// subtree sizes, accumulated from the top of the stack down
+	size := make([]int, n)
+	for i := n - 1; i >= 0; i-- {
+		id := s.order[i]
+		size[id]++
+		if s.idom[id] != ground {
+			size[s.idom[id]] += size[id]
+		}
+	}
+
+	s.falls = make([]int, n)
+	for id := range size {
+		s.falls[id] = size[id] - 1
+	}
+}
+
+// safe reports whether brick id can be disintegrated without any other brick falling.
+func (s support) safe(id int) bool {
+	for _, a := range s.above[id] {
+		if len(s.below[a]) == 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// fallers returns the bricks that fall if brick id is disintegrated: those it dominates.
+func (s support) fallers(id int) map[int]bool {
+	falling := make(map[int]bool)
+	for b := range s.bricks {
+		for d := s.idom[b]; d != ground; d = s.idom[d] {
+			if d == id {
+				falling[b] = true
+				break
+			}
+		}
+	}
+	return falling
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// label names a brick like a spreadsheet column: A..Z, then AA, AB and so on.
+func label(id int) string {
+	var out []byte
+	for id++; id > 0; id = (id - 1) / 26 {
+		out = append([]byte{byte('A' + (id-1)%26)}, out...)
+	}
+	return string(out)
+}
+
+// parseLabel is the inverse of label, also accepting a plain brick id.
+func parseLabel(raw string) (int, error) {
+	if id, err := strconv.Atoi(raw); err == nil {
+		return id, nil
+	}
+
+	id := 0
+	for _, c := range strings.ToUpper(raw) {
+		if c < 'A' || c > 'Z' {
+			return 0, fmt.Errorf("invalid brick label %q", raw)
+		}
+		id = id*26 + int(c-'A'+1)
+	}
+	if id == 0 {
+		return 0, fmt.Errorf("invalid brick label %q", raw)
+	}
+
+	return id - 1, nil
+}
+
+var axisNames = [3]string{"x", "y", "z"}
+
+// view is a 2D picture of the stack, either projected along one axis or sliced through a single
+// plane. Each cell holds the id of the brick seen there, or -1.
+type view struct {
+	title    string
+	col, row int     // axes across and up the picture
+	cols     []int   // coordinate of each column
+	rows     []int   // coordinate of each row, top first
+	cells    [][]int // cells[r][c]
+}
+
+// newView builds a view from spec: "xz" or "yz" to project the whole stack looking along y or x,
+// or "x=N", "y=N" or "z=N" to slice through a single plane.
+func (g grid) newView(spec, title string) (view, error) {
+	v := view{title: title}
+
+	depth := -1
+	var lo, hi int
+	switch {
+	case spec == "xz":
+		v.col, v.row, depth = 0, 2, 1
+		lo, hi = g.min[1], g.max[1]
+	case spec == "yz":
+		v.col, v.row, depth = 1, 2, 0
+		lo, hi = g.min[0], g.max[0]
+	case len(spec) > 2 && spec[1] == '=':
+		at, err := strconv.Atoi(spec[2:])
+		if err != nil {
+			return v, fmt.Errorf("invalid view %q: %w", spec, err)
+		}
+		lo, hi = at, at
+
+		switch spec[0] {
+		case 'x':
+			v.col, v.row, depth = 1, 2, 0
+		case 'y':
+			v.col, v.row, depth = 0, 2, 1
+		case 'z':
+			v.col, v.row, depth = 0, 1, 2
+		}
+	}
+	if depth < 0 {
+		return v, fmt.Errorf("invalid view %q: want xz, yz, x=N, y=N or z=N", spec)
+	}
+
+	for c := g.min[v.col]; c <= g.max[v.col]; c++ {
+		v.cols = append(v.cols, c)
+	}
+	if v.row == 2 { // height reads bottom up
+		for r := g.max[2]; r >= g.min[2]; r-- {
+			v.rows = append(v.rows, r)
+		}
+	} else {
+		for r := g.min[v.row]; r <= g.max[v.row]; r++ {
+			v.rows = append(v.rows, r)
+		}
+	}
+
+	for _, r := range v.rows {
+		line := make([]int, len(v.cols))
+		for i, c := range v.cols {
+			// find the front-most brick, if any
+			line[i] = -1
+			for d := lo; d <= hi; d++ {
+				var p [3]int
+				p[v.col], p[v.row], p[depth] = c, r, d
+				if id := g.at(p[0], p[1], p[2]); id >= 0 {
+					line[i] = id
+					break
+				}
+			}
+		}
+		v.cells = append(v.cells, line)
+	}
+
+	return v, nil
+}
+
+// highlight marks a brick chosen for disintegration and the bricks that would fall without it.
+type highlight struct {
+	removed int // -1 if none
+	falling map[int]bool
+}
+
+func (v view) width() int {
+	w := 1
+	for _, line := range v.cells {
+		for _, id := range line {
+			if id >= 0 {
+				w = max(w, len(label(id)))
+			}
+		}
+	}
+	return w
+}
+
+// ascii draws the view like the puzzle does, with the chosen brick drawn as #s and the bricks
+// that would fall in lower case.
+func (v view) ascii(h highlight) string {
+	w := v.width()
+	sep := ""
+	if w > 1 {
+		sep = " "
+	}
+
+	var sb strings.Builder
+	fmt.Fprintln(&sb, v.title)
+
+	span := len(v.cols)*(w+len(sep)) - len(sep)
+	fmt.Fprintf(&sb, "%*s\n", (span+1)/2, axisNames[v.col])
+
+	for r, line := range v.cells {
+		cells := make([]string, len(line))
+		for c, id := range line {
+			switch {
+			case id < 0:
+				cells[c] = strings.Repeat(".", w)
+			case id == h.removed:
+				cells[c] = strings.Repeat("#", w)
+			case h.falling[id]:
+				cells[c] = fmt.Sprintf("%-*s", w, strings.ToLower(label(id)))
+			default:
+				cells[c] = fmt.Sprintf("%-*s", w, label(id))
+			}
+		}
+		fmt.Fprintf(&sb, "%s %d", strings.Join(cells, sep), v.rows[r])
+		if r == 0 {
+			fmt.Fprintf(&sb, " %s", axisNames[v.row])
+		}
+		sb.WriteString("\n")
+	}
+
+	if v.row == 2 {
+		fmt.Fprintf(&sb, "%s 0\n", strings.Repeat("-", span))
+	}
+
+	return sb.String()
+}
+
+const cellSize = 24
+
+// writeSVG draws the views side by side, with the chosen brick in grey and the bricks that would
+// fall outlined in red.
+func writeSVG(out io.Writer, views []view, h highlight) error {
+	width, height := 0, 0
+	for _, v := range views {
+		width += (len(v.cols) + 3) * cellSize
+		height = max(height, (len(v.rows)+4)*cellSize)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="%d">`+"\n",
+		width, height, cellSize/2)
+
+	left := 0
+	for _, v := range views {
+		fmt.Fprintf(&sb, `  <text x="%d" y="%d">%s</text>`+"\n", left+cellSize, cellSize, v.title)
+
+		top := 2 * cellSize
+		for r, line := range v.cells {
+			y := top + r*cellSize
+			for c, id := range line {
+				x := left + cellSize + c*cellSize
+				if id < 0 {
+					fmt.Fprintf(&sb, `  <rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#eee"/>`+"\n", x, y, cellSize, cellSize)
+					continue
+				}
+
+				fill, stroke := fmt.Sprintf("hsl(%d,60%%,75%%)", id*137%360), "#555"
+				switch {
+				case id == h.removed:
+					fill = "#999"
+				case h.falling[id]:
+					stroke = "#d00"
+				}
+				fmt.Fprintf(&sb, `  <rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="%s"/>`+"\n", x, y, cellSize, cellSize, fill, stroke)
+				fmt.Fprintf(&sb, `  <text x="%d" y="%d" text-anchor="middle" dominant-baseline="central" textLength="%d" lengthAdjust="spacingAndGlyphs">%s</text>`+"\n",
+					x+cellSize/2, y+cellSize/2, min(len(label(id))*cellSize/2, cellSize-4), label(id))
+			}
+			fmt.Fprintf(&sb, `  <text x="%d" y="%d" dominant-baseline="central">%d</text>`+"\n", left+cellSize*(len(v.cols)+1)+4, y+cellSize/2, v.rows[r])
+		}
+
+		bottom := top + len(v.rows)*cellSize
+		if v.row == 2 {
+			fmt.Fprintf(&sb, `  <line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333" stroke-width="2"/>`+"\n",
+				left+cellSize, bottom, left+cellSize*(len(v.cols)+1), bottom)
+		}
+		fmt.Fprintf(&sb, `  <text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n",
+			left+cellSize+len(v.cols)*cellSize/2, bottom+cellSize, axisNames[v.col])
+
+		left += (len(v.cols) + 3) * cellSize
+	}
+	sb.WriteString("</svg>\n")
+
+	_, err := io.WriteString(out, sb.String())
+	return err
+}
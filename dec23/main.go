@@ -1,13 +1,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 )
 
 func parseInput() []string {
-	raw, _ := os.ReadFile(os.Args[1])
+	raw, _ := os.ReadFile(flag.Arg(0))
 	return strings.Split(strings.Trim(string(raw), "\n"), "\n")
 }
 
@@ -56,187 +60,245 @@ func draw(grid []string, seen map[point]struct{}) {
 	}
 }
 
-func part1(grid []string, start, end point) int {
-	var dfs func(p point, step int, seen map[point]struct{}) int
-
-	dfs = func(p point, step int, seen map[point]struct{}) int {
-		if p == end {
-			return step
-		}
-		seen[p] = struct{}{}
-		dirs := directions[rune(grid[p.y][p.x])]
-
-		maxDepth := -1
-		for _, d := range dirs {
-			x, y := p.x+d[0], p.y+d[1]
+// junctions is the map contracted to the junctions between corridors, with every junction
+// numbered from 0 so a set of visited junctions fits in a single bitmask.
+type junctions struct {
+	points     []point  // position of each junction
+	links      [][]link // links[i] lists the corridors leaving junction i
+	start, end int
+}
 
-			if y < 0 || y >= len(grid) || x < 0 || x >= len(grid[0]) {
-				continue
-			}
+type link struct {
+	to, weight int
+}
 
-			if grid[y][x] == '#' {
+// contract builds the junction graph of every junction reachable from start by walking the
+// corridors leaving each one, moving as dirs allows, so one-way slopes give one-way links. Where
+// two junctions are joined by more than one corridor only the longest is kept, and corridors
+// leading back to the junction they left are dropped, since no hike could use them. Junctions are
+// numbered in reading order.
+func contract(grid []string, start, end point, dirs map[rune][][2]int) (junctions, error) {
+	weights := map[point]map[point]int{start: {}}
+	queue := []point{start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for _, c := range corridors(grid, cur, dirs) {
+			to := c[len(c)-1]
+			if to == cur {
 				continue
 			}
 
-			next := point{x, y}
-			if _, ok := seen[next]; ok {
-				continue
+			weights[cur][to] = max(weights[cur][to], len(c))
+			if _, ok := weights[to]; !ok {
+				weights[to] = make(map[point]int)
+				queue = append(queue, to)
 			}
-			maxDepth = max(maxDepth, dfs(next, step+1, seen))
 		}
-
-		delete(seen, p)
-		return maxDepth
 	}
-
-	return dfs(start, 0, make(map[point]struct{}))
-}
-
-type edge struct {
-	from, to point
-	weight   int
-}
-
-func (e edge) String() string {
-	return fmt.Sprintf("%s --%d--> %s", e.from, e.weight, e.to)
-}
-
-type graph struct {
-	edges map[point]map[edge]struct{}
-}
-
-func (g *graph) addEdge(from, to point, weight int) {
-	if _, ok := g.edges[from]; !ok {
-		g.edges[from] = make(map[edge]struct{})
+	if _, ok := weights[end]; !ok {
+		weights[end] = make(map[point]int) // unreachable, but still needs a number
 	}
-	g.edges[from][edge{from, to, weight}] = struct{}{}
 
-	// undirected
-	if _, ok := g.edges[to]; !ok {
-		g.edges[to] = make(map[edge]struct{})
+	var j junctions
+	for p := range weights {
+		j.points = append(j.points, p)
 	}
-
-	g.edges[to][edge{to, from, weight}] = struct{}{}
-}
-
-func (g *graph) removePoint(p point) {
-	edges := g.edges[p]
-	var points []point
-	weight := 0
-	for e := range edges {
-		points = append(points, e.to)
-		weight += e.weight
-		delete(edges, e)
+	if len(j.points) > 64 {
+		return j, fmt.Errorf("%d junctions is too many to track in a 64-bit visited set", len(j.points))
 	}
-	delete(g.edges, p)
+	slices.SortFunc(j.points, func(a, b point) int {
+		if a.y != b.y {
+			return a.y - b.y
+		}
+		return a.x - b.x
+	})
 
-	// undirected
-	for _, pp := range points {
-		edges := g.edges[pp]
+	index := make(map[point]int, len(j.points))
+	for i, p := range j.points {
+		index[p] = i
+	}
 
-		for e := range edges {
-			if e.to == p {
-				delete(edges, e)
-			}
+	j.links = make([][]link, len(j.points))
+	for i, p := range j.points {
+		for to, weight := range weights[p] {
+			j.links[i] = append(j.links[i], link{index[to], weight})
 		}
+		slices.SortFunc(j.links[i], func(a, b link) int {
+			return a.to - b.to
+		})
 	}
 
-	// add the consolidated edge
-	g.addEdge(points[0], points[1], weight)
+	j.start, j.end = index[start], index[end]
+	return j, nil
 }
 
-func (g *graph) compress() {
-	var points []point
+// hike is a partial route through the junctions: where it is, where it has been, and how far it
+// has come.
+type hike struct {
+	at      int
+	visited uint64
+	steps   int
+	trail   []int // junctions passed through so far, ending with at
+}
 
-	for p, edges := range g.edges {
-		// remove only points that are not an intersection
-		if len(edges) == 2 {
-			points = append(points, p)
+// splitDepth is how many junctions deep the search branches before handing each partial hike to a
+// worker.
+const splitDepth = 6
+
+// longest returns the longest hike from start to end that never revisits a junction, as its
+// length and the junctions it passes through, or -1 and nil if there is none. The search is split
+// across workers once it has branched splitDepth junctions deep.
+func (j junctions) longest(workers int) (int, []int) {
+	// when the end can only be reached from a single junction, a hike reaching that junction must
+	// head straight for the end; going anywhere else would cut it off
+	last, lastWeight, into := -1, 0, 0
+	for i, links := range j.links {
+		for _, l := range links {
+			if l.to == j.end && i != j.end {
+				last, lastWeight = i, l.weight
+				into++
+			}
 		}
 	}
+	if into != 1 {
+		last = -1
+	}
+
+	// search explores every hike continuing from h, keeping the longest in best. Each hike's trail
+	// shares its backing array with its siblings', so only finished trails are copied.
+	var search func(h hike, best *hike)
+	search = func(h hike, best *hike) {
+		switch h.at {
+		case j.end:
+			if h.steps > best.steps {
+				*best = hike{at: h.at, steps: h.steps, trail: slices.Clone(h.trail)}
+			}
+			return
+		case last:
+			search(hike{j.end, h.visited | 1<<h.at, h.steps + lastWeight, append(h.trail, j.end)}, best)
+			return
+		}
 
-	for _, p := range points {
-		g.removePoint(p)
+		visited := h.visited | 1<<h.at
+		for _, l := range j.links[h.at] {
+			if visited&(1<<l.to) == 0 {
+				search(hike{l.to, visited, h.steps + l.weight, append(h.trail, l.to)}, best)
+			}
+		}
 	}
-}
 
-func newGraph(grid []string) *graph {
-	g := &graph{
-		edges: make(map[point]map[edge]struct{}),
+	origin := hike{at: j.start, trail: []int{j.start}}
+	if workers <= 1 {
+		best := hike{steps: -1}
+		search(origin, &best)
+		return best.steps, best.trail
 	}
 
-	for y, row := range grid {
-		for x, c := range row {
-			if c == '#' {
+	// branch out breadth first to get enough partial hikes to keep every worker busy
+	hikes := []hike{origin}
+	for depth := 0; depth < splitDepth; depth++ {
+		var next []hike
+		for _, h := range hikes {
+			if h.at == j.end || h.at == last {
+				next = append(next, h)
 				continue
 			}
-			a := point{x, y}
-			for _, d := range directions2[rune(grid[y][x])] {
-				nx, ny := x+d[0], y+d[1]
 
-				if ny < 0 || ny >= len(grid) || nx < 0 || nx >= len(grid[0]) {
-					continue
+			visited := h.visited | 1<<h.at
+			for _, l := range j.links[h.at] {
+				if visited&(1<<l.to) == 0 {
+					trail := append(slices.Clone(h.trail), l.to)
+					next = append(next, hike{l.to, visited, h.steps + l.weight, trail})
 				}
-
-				if grid[ny][nx] == '#' {
-					continue
-				}
-
-				b := point{nx, ny}
-				g.addEdge(a, b, 1)
 			}
 		}
+		hikes = next
 	}
 
-	return g
-}
+	indexes := make(chan int)
+	results := make(chan hike)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				best := hike{steps: -1}
+				search(hikes[i], &best)
+				results <- best
+			}
+		}()
+	}
 
-func part2(grid []string, start, end point) int {
-	g := newGraph(grid)
-	g.compress()
-
-	// // display intersections
-	// for p, edges := range g.edges {
-	// 	if len(edges) > 2 {
-	// 		for edge := range edges {
-	// 			fmt.Printf("Intesection at point %s, has edge %s\n", p, edge)
-	// 		}
-	// 	} else {
-	// 		fmt.Printf("point %s, DOES NOT have 2 edges: %+v\n", p, edges)
-	// 	}
-	// }
-
-	var dfs func(p point, step int, seen map[point]struct{}) int
-
-	dfs = func(p point, step int, seen map[point]struct{}) int {
-		if p == end {
-			return step
+	go func() {
+		for i := range hikes {
+			indexes <- i
 		}
+		close(indexes)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	best := hike{steps: -1}
+	for r := range results {
+		// prefer the lexically smallest trail between equally long hikes so every run agrees
+		if r.steps > best.steps || r.steps == best.steps && slices.Compare(r.trail, best.trail) < 0 {
+			best = r
+		}
+	}
 
-		seen[p] = struct{}{}
-		maxDepth := -1
-		for e := range g.edges[p] {
-
-			next := e.to
-			if _, ok := seen[next]; ok {
-				continue
-			}
+	return best.steps, best.trail
+}
 
-			maxDepth = max(maxDepth, dfs(next, step+e.weight, seen))
-		}
+// solve returns the longest hike from start to end that never steps on the same tile twice,
+// moving as dirs allows.
+func solve(grid []string, start, end point, dirs map[rune][][2]int, workers int) route {
+	j, err := contract(grid, start, end, dirs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-		delete(seen, p)
-		return maxDepth
+	steps, trail := j.longest(workers)
+	r := route{steps: steps}
+	for _, i := range trail {
+		r.junctions = append(r.junctions, j.points[i])
 	}
+	r.tiles = expand(grid, r.junctions, dirs)
 
-	total := dfs(start, 0, make(map[point]struct{}))
-	return total
+	return r
+}
+
+// part1 follows the slopes downhill.
+func part1(grid []string, start, end point, workers int) route {
+	return solve(grid, start, end, directions, workers)
+}
+
+// part2 climbs slopes as if they were paths.
+func part2(grid []string, start, end point, workers int) route {
+	return solve(grid, start, end, directions2, workers)
 }
 
 func main() {
+	workers := flag.Int("workers", runtime.NumCPU(), "split each search across this many goroutines; 1 searches sequentially")
+	show := flag.Bool("draw", false, "list the junctions along each longest hike and draw it on the map")
+	flag.Parse()
+
 	grid := parseInput()
 	start, end := point{1, 0}, point{len(grid[0]) - 2, len(grid) - 1}
 
-	fmt.Println("Part 1:", part1(grid, start, end))
-	fmt.Println("Part 2:", part2(grid, start, end))
+	for i, r := range []route{part1(grid, start, end, *workers), part2(grid, start, end, *workers)} {
+		fmt.Printf("Part %d: %d\n", i+1, r.steps)
+		if *show {
+			fmt.Println(r)
+			draw(grid, r.visited())
+			fmt.Println()
+		}
+	}
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// bruteForce returns the longest hike from start to end that never steps on the same tile twice,
+// moving as dirs allows, by searching tile by tile. It is far too slow for the climbing rules on a
+// full map, but serves as the reference the junction graph is checked against.
+func bruteForce(grid []string, start, end point, dirs map[rune][][2]int) route {
+	best := route{steps: -1}
+	var trail []point
+
+	var dfs func(p point, step int, seen map[point]struct{})
+
+	dfs = func(p point, step int, seen map[point]struct{}) {
+		trail = append(trail, p)
+		defer func() { trail = trail[:len(trail)-1] }()
+
+		if p == end {
+			if step > best.steps {
+				best.steps, best.tiles = step, slices.Clone(trail)
+			}
+			return
+		}
+		seen[p] = struct{}{}
+
+		for _, d := range dirs[rune(grid[p.y][p.x])] {
+			next := point{p.x + d[0], p.y + d[1]}
+			if !open(grid, next) {
+				continue
+			}
+
+			if _, ok := seen[next]; ok {
+				continue
+			}
+			dfs(next, step+1, seen)
+		}
+
+		delete(seen, p)
+	}
+
+	dfs(start, 0, make(map[point]struct{}))
+
+	for _, p := range best.tiles {
+		if junction(grid, p) {
+			best.junctions = append(best.junctions, p)
+		}
+	}
+	return best
+}
+
+// randomMap generates a w by h map laid out like the puzzle's: forest around the edge, the start
+// in the top row, the end in the bottom row, and open paths and slopes scattered in between.
+func randomMap(rng *rand.Rand, w, h int) []string {
+	const slopes = "^v<>"
+
+	grid := make([]string, h)
+	for y := range grid {
+		row := []byte(strings.Repeat("#", w))
+		for x := 1; x < w-1 && y > 0 && y < h-1; x++ {
+			switch r := rng.Float64(); {
+			case r < 0.1:
+				row[x] = slopes[rng.Intn(len(slopes))]
+			case r < 0.75:
+				row[x] = '.'
+			}
+		}
+		grid[y] = string(row)
+	}
+
+	// open the way in and out
+	for _, p := range []point{{1, 0}, {1, 1}, {w - 2, h - 2}, {w - 2, h - 1}} {
+		row := []byte(grid[p.y])
+		row[p.x] = '.'
+		grid[p.y] = string(row)
+	}
+
+	return grid
+}
+
+// checkRoute reports the first way r fails to be a hike from start to end of its stated length
+// that moves as dirs allows and never steps on the same tile twice.
+func checkRoute(grid []string, r route, start, end point, dirs map[rune][][2]int) error {
+	if r.steps < 0 {
+		return nil
+	}
+	if len(r.tiles) != r.steps+1 || r.tiles[0] != start || r.tiles[len(r.tiles)-1] != end {
+		return fmt.Errorf("%d tiles do not make a %d step hike from %s to %s", len(r.tiles), r.steps, start, end)
+	}
+
+	seen := make(map[point]struct{}, len(r.tiles))
+	for i, p := range r.tiles {
+		if _, ok := seen[p]; ok || !open(grid, p) {
+			return fmt.Errorf("hike steps on %s twice or into the forest", p)
+		}
+		seen[p] = struct{}{}
+
+		if i == 0 {
+			continue
+		}
+		prev, moved := r.tiles[i-1], false
+		for _, d := range dirs[rune(grid[prev.y][prev.x])] {
+			moved = moved || point{prev.x + d[0], prev.y + d[1]} == p
+		}
+		if !moved {
+			return fmt.Errorf("hike cannot step from %s to %s", prev, p)
+		}
+	}
+
+	return nil
+}
+
+// checkContract compares the longest hike through the junction graph, searched sequentially and
+// in parallel, against a tile by tile search, and checks it expands into a real hike.
+func checkContract(t *testing.T, name string, grid []string, dirs map[rune][][2]int) int {
+	t.Helper()
+	start, end := point{1, 0}, point{len(grid[0]) - 2, len(grid) - 1}
+
+	want := bruteForce(grid, start, end, dirs)
+	for _, workers := range []int{1, runtime.NumCPU()} {
+		got := solve(grid, start, end, dirs, workers)
+		if got.steps != want.steps {
+			t.Fatalf("%s with %d workers: junctions give %d steps, tile by tile gives %d\n%s",
+				name, workers, got.steps, want.steps, strings.Join(grid, "\n"))
+		}
+		if err := checkRoute(grid, got, start, end, dirs); err != nil {
+			t.Fatalf("%s with %d workers: %v\n%s", name, workers, err, strings.Join(grid, "\n"))
+		}
+	}
+
+	return want.steps
+}
+
+func TestContractExample(t *testing.T) {
+	raw, err := os.ReadFile("example.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grid := strings.Split(strings.Trim(string(raw), "\n"), "\n")
+
+	for part, tc := range []struct {
+		dirs map[rune][][2]int
+		want int
+	}{{directions, 94}, {directions2, 154}} {
+		name := fmt.Sprintf("part %d", part+1)
+		if got := checkContract(t, name, grid, tc.dirs); got != tc.want {
+			t.Errorf("%s: got %d steps, want %d", name, got, tc.want)
+		}
+	}
+}
+
+func TestContractRandomMaps(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		grid := randomMap(rng, 5+rng.Intn(5), 5+rng.Intn(5))
+		for part, dirs := range []map[rune][][2]int{directions, directions2} {
+			checkContract(t, fmt.Sprintf("random map %d, part %d", i, part+1), grid, dirs)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// route is a hike from the start to the end of the map.
+type route struct {
+	steps     int
+	junctions []point // start, every junction passed through, and end
+	tiles     []point // every tile stepped on, start and end included
+}
+
+func (r route) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d steps through %d junctions:", r.steps, len(r.junctions))
+	for i, p := range r.junctions {
+		if i > 0 {
+			b.WriteString(" ->")
+		}
+		fmt.Fprintf(&b, " %s", p)
+	}
+	return b.String()
+}
+
+// visited returns the set of tiles the route steps on, for draw.
+func (r route) visited() map[point]struct{} {
+	seen := make(map[point]struct{}, len(r.tiles))
+	for _, p := range r.tiles {
+		seen[p] = struct{}{}
+	}
+	return seen
+}
+
+// open reports whether p is on the map and not forest.
+func open(grid []string, p point) bool {
+	return p.y >= 0 && p.y < len(grid) && p.x >= 0 && p.x < len(grid[p.y]) && grid[p.y][p.x] != '#'
+}
+
+// junction reports whether p is anything other than a tile partway along a corridor: a fork, a
+// dead end, or the start or end of the map.
+func junction(grid []string, p point) bool {
+	n := 0
+	for _, d := range directions['.'] {
+		if open(grid, point{p.x + d[0], p.y + d[1]}) {
+			n++
+		}
+	}
+	return n != 2
+}
+
+// corridors follows every corridor leaving junction from, moving as dirs allows, and returns the
+// tiles along each one that reaches another junction, ending with that junction.
+func corridors(grid []string, from point, dirs map[rune][][2]int) [][]point {
+	var found [][]point
+	for _, d := range dirs[rune(grid[from.y][from.x])] {
+		prev, cur := from, point{from.x + d[0], from.y + d[1]}
+		if !open(grid, cur) {
+			continue
+		}
+
+		path := []point{cur}
+		for !junction(grid, cur) {
+			next, ok := cur, false
+			for _, d := range dirs[rune(grid[cur.y][cur.x])] {
+				p := point{cur.x + d[0], cur.y + d[1]}
+				if p != prev && open(grid, p) {
+					next, ok = p, true
+					break
+				}
+			}
+			if !ok {
+				break // a slope points back the way we came
+			}
+
+			prev, cur = cur, next
+			path = append(path, cur)
+		}
+
+		if junction(grid, cur) {
+			found = append(found, path)
+		}
+	}
+
+	return found
+}
+
+// expand fills in the tiles between each pair of junctions along a route, taking the longest
+// corridor where two junctions are joined by more than one.
+func expand(grid []string, junctions []point, dirs map[rune][][2]int) []point {
+	if len(junctions) == 0 {
+		return nil
+	}
+
+	tiles := []point{junctions[0]}
+	for i := 1; i < len(junctions); i++ {
+		var longest []point
+		for _, c := range corridors(grid, junctions[i-1], dirs) {
+			if c[len(c)-1] == junctions[i] && len(c) > len(longest) {
+				longest = c
+			}
+		}
+		tiles = append(tiles, longest...)
+	}
+
+	return tiles
+}
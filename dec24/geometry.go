@@ -0,0 +1,183 @@
+package main
+
+import "math/big"
+
+// rat returns n as an exact rational.
+func rat(n int64) *big.Rat {
+	return new(big.Rat).SetInt64(n)
+}
+
+// cross returns the z component of the cross product of the x,y parts of u and v, which is zero
+// exactly when they are parallel.
+func cross(u, v [3]int64) *big.Int {
+	a := new(big.Int).Mul(big.NewInt(u[0]), big.NewInt(v[1]))
+	b := new(big.Int).Mul(big.NewInt(u[1]), big.NewInt(v[0]))
+	return a.Sub(a, b)
+}
+
+type crossing int
+
+const (
+	crosses    crossing = iota // the paths meet at a single point
+	parallel                   // the paths never meet
+	coincident                 // the paths lie along the same line
+)
+
+// intersect finds where the paths of a and b cross in the x,y plane, returning the times t and u
+// at which a and b respectively reach that point. The times are only set when the paths cross at
+// a single point.
+func intersect(a, b hailstone) (t, u *big.Rat, c crossing) {
+	// a.p + a.v*t = b.p + b.v*u, solved for t and u by Cramer's rule
+	d := [3]int64{b.p[0] - a.p[0], b.p[1] - a.p[1]}
+	det := cross(b.v, a.v)
+
+	if det.Sign() == 0 {
+		// a hailstone standing still in x,y has no line, so two of them only share a path when
+		// they stand on the same point
+		still := a.v[0] == 0 && a.v[1] == 0 && b.v[0] == 0 && b.v[1] == 0
+		if still && (d[0] != 0 || d[1] != 0) {
+			return nil, nil, parallel
+		}
+		if cross(a.v, d).Sign() == 0 && cross(b.v, d).Sign() == 0 {
+			return nil, nil, coincident
+		}
+		return nil, nil, parallel
+	}
+
+	t = new(big.Rat).SetFrac(cross(b.v, d), det)
+	u = new(big.Rat).SetFrac(cross(a.v, d), det)
+	return t, u, crosses
+}
+
+// at returns the position of h along axis at time t.
+func (h hailstone) at(axis int, t *big.Rat) *big.Rat {
+	pos := new(big.Rat).Mul(rat(h.v[axis]), t)
+	return pos.Add(pos, rat(h.p[axis]))
+}
+
+// span is a closed interval of times, unbounded at either end where nil.
+type span struct {
+	lo, hi *big.Rat
+}
+
+func (s span) empty() bool {
+	return s.lo != nil && s.hi != nil && s.lo.Cmp(s.hi) > 0
+}
+
+// clip narrows s to the times at which p + v*t lies within [lo, hi].
+func (s span) clip(p, v int64, lo, hi *big.Rat) span {
+	if v == 0 {
+		if x := rat(p); x.Cmp(lo) < 0 || x.Cmp(hi) > 0 {
+			return span{rat(1), rat(0)}
+		}
+		return s
+	}
+
+	// (bound - p) / v, flipping the ends for a negative velocity
+	from := new(big.Rat).Quo(new(big.Rat).Sub(lo, rat(p)), rat(v))
+	to := new(big.Rat).Quo(new(big.Rat).Sub(hi, rat(p)), rat(v))
+	if v < 0 {
+		from, to = to, from
+	}
+
+	return s.narrow(from, to)
+}
+
+// narrow intersects s with [from, to], either of which may be nil for unbounded.
+func (s span) narrow(from, to *big.Rat) span {
+	if from != nil && (s.lo == nil || from.Cmp(s.lo) > 0) {
+		s.lo = from
+	}
+	if to != nil && (s.hi == nil || to.Cmp(s.hi) < 0) {
+		s.hi = to
+	}
+	return s
+}
+
+// overlapIn reports whether the future paths of a and b, known to lie along the same line in the
+// x,y plane, share any point within the square [lo, hi] in x and y.
+func overlapIn(a, b hailstone, lo, hi *big.Rat) bool {
+	if a.v[0] == 0 && a.v[1] == 0 {
+		a, b = b, a
+	}
+
+	// measure along the line by a's time, picking an axis a moves along
+	axis := 0
+	if a.v[0] == 0 {
+		axis = 1
+	}
+	if a.v[axis] == 0 {
+		// neither moves in x,y, so each path is a single point
+		if a.p[0] != b.p[0] || a.p[1] != b.p[1] {
+			return false
+		}
+		s := span{}
+		for axis := 0; axis < 2; axis++ {
+			s = s.clip(a.p[axis], 0, lo, hi)
+		}
+		return !s.empty()
+	}
+
+	// b starts at a's time s0 and moves through a's times at rate b.v/a.v
+	s0 := new(big.Rat).SetFrac64(b.p[axis]-a.p[axis], a.v[axis])
+	s := span{lo: rat(0)}
+	switch rate := b.v[axis] * a.v[axis]; {
+	case rate > 0:
+		s = s.narrow(s0, nil)
+	case rate < 0:
+		s = s.narrow(nil, s0)
+	default:
+		s = s.narrow(s0, s0)
+	}
+
+	for axis := 0; axis < 2; axis++ {
+		s = s.clip(a.p[axis], a.v[axis], lo, hi)
+	}
+	return !s.empty()
+}
+
+// collide returns the time from 0 on at which a and b are in the same place in all of x, y and z,
+// if there is one. Hailstones starting in the same place and moving together collide at 0.
+func collide(a, b hailstone) (*big.Rat, bool) {
+	// a.p + a.v*t = b.p + b.v*t, so (a.v - b.v)*t = b.p - a.p along every axis
+	var t *big.Rat
+	for axis := 0; axis < 3; axis++ {
+		dp, dv := b.p[axis]-a.p[axis], a.v[axis]-b.v[axis]
+		if dv == 0 {
+			if dp != 0 {
+				return nil, false // always apart along this axis
+			}
+			continue
+		}
+
+		at := new(big.Rat).SetFrac64(dp, dv)
+		if t != nil && t.Cmp(at) != 0 {
+			return nil, false
+		}
+		t = at
+	}
+
+	if t == nil {
+		t = rat(0)
+	}
+	return t, t.Sign() >= 0
+}
+
+// collision is a pair of hailstones, by index, meeting at time t.
+type collision struct {
+	a, b int
+	t    *big.Rat
+}
+
+// collisions returns every pair of hailstones that collide, in input order.
+func collisions(h []hailstone) []collision {
+	var found []collision
+	for i := range h {
+		for j := i + 1; j < len(h); j++ {
+			if t, ok := collide(h[i], h[j]); ok {
+				found = append(found, collision{i, j, t})
+			}
+		}
+	}
+	return found
+}
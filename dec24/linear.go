@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+)
+
+// solveLinear solves a system of linear equations in n unknowns exactly by Gauss-Jordan
+// elimination. Each row holds the n coefficients of an equation followed by its constant, and is
+// overwritten. There may be more equations than unknowns, so long as they all agree.
+func solveLinear(rows [][]*big.Rat, n int) ([]*big.Rat, error) {
+	r := 0
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for i := r; i < len(rows); i++ {
+			if rows[i][col].Sign() != 0 {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, errors.New("not enough independent equations to pin down every unknown")
+		}
+		rows[r], rows[pivot] = rows[pivot], rows[r]
+
+		inv := new(big.Rat).Inv(rows[r][col])
+		for k := col; k <= n; k++ {
+			rows[r][k].Mul(rows[r][k], inv)
+		}
+
+		for i := range rows {
+			if i == r || rows[i][col].Sign() == 0 {
+				continue
+			}
+			f := new(big.Rat).Set(rows[i][col])
+			for k := col; k <= n; k++ {
+				rows[i][k].Sub(rows[i][k], new(big.Rat).Mul(f, rows[r][k]))
+			}
+		}
+		r++
+	}
+
+	// every equation beyond the first n is now 0 = constant
+	for _, row := range rows[n:] {
+		if row[n].Sign() != 0 {
+			return nil, errors.New("equations contradict each other")
+		}
+	}
+
+	solution := make([]*big.Rat, n)
+	for i := range solution {
+		solution[i] = rows[i][n]
+	}
+	return solution, nil
+}
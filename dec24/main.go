@@ -1,26 +1,30 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"math/big"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
-var re = regexp.MustCompile(`^(\d+),\s+(\d+),\s+(\d+)\s+@\s+(-?\d+),\s+(-?\d+),\s+(-?\d+)`)
+var re = regexp.MustCompile(`^(-?\d+),\s+(-?\d+),\s+(-?\d+)\s+@\s+(-?\d+),\s+(-?\d+),\s+(-?\d+)`)
 
+// hailstone positions and velocities are kept as integers, and everything derived from them is
+// worked out exactly with big.Rat; coordinates around 10^14 are beyond what float64 can multiply
+// and divide without rounding.
 type hailstone struct {
-	px, py, pz float64
-	vx, vy, vz float64
+	p, v [3]int64
 }
 
 func (h hailstone) String() string {
-	return fmt.Sprintf("(%.0f,%.0f,%.0f), (%.0f,%.0f,%.0f)", h.px, h.py, h.pz, h.vx, h.vy, h.vz)
+	return fmt.Sprintf("%d, %d, %d @ %d, %d, %d", h.p[0], h.p[1], h.p[2], h.v[0], h.v[1], h.v[2])
 }
 
 func parseInput() []hailstone {
-	raw, _ := os.ReadFile(os.Args[1])
+	raw, _ := os.ReadFile(flag.Arg(0))
 	lines := strings.Split(strings.Trim(string(raw), "\n"), "\n")
 
 	var hailstones []hailstone
@@ -28,193 +32,203 @@ func parseInput() []hailstone {
 	for _, line := range lines {
 		match := re.FindStringSubmatch(line)
 
-		out := make([]float64, 6)
-
-		for i := 0; i < len(match)-1; i++ {
-			n, _ := strconv.Atoi(match[i+1])
-			out[i] = float64(n)
-
+		var h hailstone
+		for i := 0; i < 3; i++ {
+			h.p[i], _ = strconv.ParseInt(match[i+1], 10, 64)
+			h.v[i], _ = strconv.ParseInt(match[i+4], 10, 64)
 		}
-		px, py, pz, vx, vy, vz := out[0], out[1], out[2], out[3], out[4], out[5]
-		hailstones = append(hailstones, hailstone{px, py, pz, vx, vy, vz})
+		hailstones = append(hailstones, h)
 	}
 
 	return hailstones
 }
 
-func slope(vx, vy float64) float64 {
-	if vx != 0 {
-		return vy / vx
-	}
-
-	return 0
-}
-
-func intersectTime(a, b hailstone) float64 {
-	// x(t) = x + vx * t
-	// y(t) = y + vy * t
-	// a.px + a.vx*t = b.px + b.vx*t
-	// a.vx*t - b.vx*t = b.px - a.px
-	// t(a.vx - b.vx) = b.px - a.px
-	// t = (b.px - a.px) / (a.vx - b.vx)
-
-	// if a.vx == b.vx {
-	// 	return 0
-	// }
-
-	t := (b.px - a.px) / (a.vx - b.vx)
-	return t
-}
-
-func part1(h []hailstone) int {
-
-	const (
-		MIN = 200000000000000
-		MAX = 400000000000000
-	)
+// part1 counts the pairs of hailstones whose paths cross in the x,y plane, going forward, within
+// the test area from low to high in both x and y.
+func part1(h []hailstone, low, high int64) int {
+	lo, hi := rat(low), rat(high)
 
 	sum := 0
 	for i := 0; i < len(h); i++ {
 		for j := i + 1; j < len(h); j++ {
-			// fmt.Printf("Hailstone A: %s\n", h[i])
-			// fmt.Printf("Hailstone B: %s\n", h[j])
-
 			a, b := h[i], h[j]
 
-			// Line: y = mx + b
-			// m = slope
-			// b = y-intercept
-
-			// slope = dy/dx
-			ma := slope(a.vx, a.vy)
-			mb := slope(b.vx, b.vy)
-			// y-intercept
-			ba := a.py - ma*a.px
-			bb := b.py - mb*b.px
-
-			// Line A: y = mx+b
-			// Line B: y = mx+b
-			// ma*x + ba = mb*x+bb
-			// ma*x - mb*x = bb - ba
-			// x*(ma - mb) = bb - ba
-			x := (bb - ba) / (ma - mb)
-			y := ma*x + ba
-			// fmt.Printf("Intersection X: %.3f\n", x)
-			// fmt.Printf("Intersection Y: %.3f\n", y)
-
-			if x < MIN || x > MAX || y < MIN || y > MAX {
+			t, u, c := intersect(a, b)
+			switch c {
+			case parallel:
+				continue
+			case coincident:
+				if overlapIn(a, b, lo, hi) {
+					sum++
+				}
 				continue
 			}
 
-			if x < a.px && a.vx > 0 || x > a.px && a.vx < 0 ||
-				y < a.py && a.vy > 0 || y > a.py && a.vy < 0 || // A in the past
-				x < b.px && b.vx > 0 || x > b.px && b.vx < 0 ||
-				y < b.py && b.vy > 0 || y > b.py && b.vy < 0 { // B in the past
+			if t.Sign() < 0 || u.Sign() < 0 { // crossed in the past
+				continue
+			}
+
+			x, y := a.at(0, t), a.at(1, t)
+			if x.Cmp(lo) < 0 || x.Cmp(hi) > 0 || y.Cmp(lo) < 0 || y.Cmp(hi) > 0 {
 				continue
 			}
 
 			sum++
 		}
-
-		fmt.Println()
 	}
 
 	return sum
 }
 
-func part2(h []hailstone) int {
+// rock is a thrown rock's starting position and velocity.
+type rock struct {
+	p, v [3]*big.Rat
+}
 
-	// For each candidate rock velocity (-500 to 500), considering just the first two hailstones and only the x/y coordinates.
-	// Assuming the rock intercepts both these hailstones, use the same approach as part 1 to calculate the interception point & time,
-	// and from that calculate the start position of the rock. We then test this origin & velocity to see if it fits all x/y/z coordinates
-	// for all hailstones.
+func (r rock) String() string {
+	return fmt.Sprintf("%s, %s, %s @ %s, %s, %s",
+		r.p[0].RatString(), r.p[1].RatString(), r.p[2].RatString(),
+		r.v[0].RatString(), r.v[1].RatString(), r.v[2].RatString())
+}
 
-	const RANGE = 500
+// hits reports whether the rock hits every hailstone at some time from 0 on.
+func (r rock) hits(h []hailstone) bool {
+	for _, hs := range h {
+		// relative to the rock, the hailstone must pass through the rock's start
+		var rel [3]*big.Rat
+		for axis := range rel {
+			rel[axis] = new(big.Rat).Sub(rat(hs.v[axis]), r.v[axis])
+		}
 
-	h1 := h[0]
-	h2 := h[1]
-	// ma := slope(h1.vx, h1.vy)
-	// mb := slope(h2.vx, h2.vy)
-	// // y-intercept
-	// ba := h1.py - ma*h1.px
-	// bb := h2.py - mb*h2.px
+		u := rat(0) // moving together, so only a hit if they start in the same place
+		for axis := range rel {
+			if rel[axis].Sign() != 0 {
+				u.Sub(r.p[axis], rat(hs.p[axis]))
+				u.Quo(u, rel[axis])
+				break
+			}
+		}
+		if u.Sign() < 0 {
+			return false
+		}
 
-	for i := -RANGE; i <= RANGE; i++ {
-		for j := -RANGE; j <= RANGE; j++ {
-			for k := -RANGE; k <= RANGE; k++ {
+		for axis := range rel {
+			pos := new(big.Rat).Mul(rel[axis], u)
+			if pos.Add(pos, rat(hs.p[axis])).Cmp(r.p[axis]) != 0 {
+				return false
+			}
+		}
+	}
 
-				if i == 0 || j == 0 || k == 0 {
-					continue
-				}
+	return true
+}
 
-				vx, vy, vz := float64(i), float64(j), float64(k)
-				// simultaneous linear equation (from part 1):
-				// H1:  x = A + a*t   y = B + b*t
-				// H2:  x = C + c*u   y = D + d*u
-				//
-				//  t = [ d ( C - A ) - c ( D - B ) ] / ( a * d - b * c )
-				//
-				// Solve for origin of rock intercepting both hailstones in x,y:
-				//     x = A + a*t - vx*t   y = B + b*t - vy*t
-				//     x = C + c*u - vx*u   y = D + d*u - vy*u
-
-				// x(t) = x + vx * t
-				// y(t) = y + vy * t
-
-				A, a := h1.px, h1.vx-vx
-				B, b := h1.py, h1.vy-vy
-				C, c := h2.px, h2.vx-vx
-				D, d := h2.py, h2.vy-vy
-
-				if c == 0 || (a*d)-(b*c) == 0 {
-					continue
-				}
+// crossRat returns the cross product of a and b.
+func crossRat(a, b [3]*big.Rat) [3]*big.Rat {
+	mul := func(x, y *big.Rat) *big.Rat {
+		return new(big.Rat).Mul(x, y)
+	}
 
-				// rock intercepts H1 at time t
-				t := (d*(C-A) - c*(D-B)) / ((a * d) - (b * c))
-
-				// Calculate starting position of rock from intercept point
-				x := h1.px + h1.vx*t - vx*t
-				y := h1.py + h1.vy*t - vy*t
-				z := h1.pz + h1.vz*t - vz*t
-
-				// check all hailstones
-
-				valid := true
-				for _, hs := range h {
-
-					var u float64
-					if hs.vx != vx {
-						u = (x - hs.px) / (hs.vx - vx)
-					} else if hs.vy != vy {
-						u = (y - hs.py) / (hs.vy - vy)
-					} else if hs.vz != vz {
-						u = (z - hs.pz) / (hs.vz - vz)
-					} else {
-						panic("invalid input")
-					}
-
-					if (x+u*vx != hs.px+u*hs.vx) || (y+u*vy != hs.py+u*hs.vy) || (z+u*vz != hs.pz+u*hs.vz) {
-						valid = false
-						break
-					}
-				}
+	var c [3]*big.Rat
+	for i := range c {
+		j, k := (i+1)%3, (i+2)%3
+		c[i] = new(big.Rat).Sub(mul(a[j], b[k]), mul(a[k], b[j]))
+	}
+	return c
+}
 
-				if valid {
-					fmt.Printf("%0.0f,%0.0f,%0.0f, %.0f,%.0f,%.0f\n", x, y, z, vx, vy, vz)
-					return int(x + y + z)
-				}
-			}
+func vector(v [3]int64) [3]*big.Rat {
+	return [3]*big.Rat{rat(v[0]), rat(v[1]), rat(v[2])}
+}
+
+func part2(h []hailstone) (rock, error) {
+
+	// The rock starting at P with velocity V hits hailstone i at some time t:
+	//   P + V*t = p_i + v_i*t, so (P - p_i) = -t(V - v_i)
+	// which means P - p_i and V - v_i are parallel, and their cross product is zero:
+	//   P×V - P×v_i - p_i×V + p_i×v_i = 0
+	// P×V is the same for every hailstone, so subtracting the equations for hailstones i and j
+	// leaves three equations linear in P and V:
+	//   P×(v_j - v_i) + (p_j - p_i)×V = p_j×v_j - p_i×v_i
+	// Pairing the first hailstone with every other gives far more equations than the six unknowns,
+	// all of which must agree. The solution is then checked to hit every hailstone going forward.
+
+	var rows [][]*big.Rat
+	for j := 1; j < len(h); j++ {
+		pi, vi := vector(h[0].p), vector(h[0].v)
+		pj, vj := vector(h[j].p), vector(h[j].v)
+
+		var w, d [3]*big.Rat // v_j - v_i and p_j - p_i
+		for axis := range w {
+			w[axis] = new(big.Rat).Sub(vj[axis], vi[axis])
+			d[axis] = new(big.Rat).Sub(pj[axis], pi[axis])
+		}
+
+		ci, cj := crossRat(pi, vi), crossRat(pj, vj)
+		neg := func(x *big.Rat) *big.Rat {
+			return new(big.Rat).Neg(x)
 		}
 
+		// component-wise coefficients of Px, Py, Pz, Vx, Vy, Vz and the constant
+		rows = append(rows,
+			[]*big.Rat{rat(0), w[2], neg(w[1]), rat(0), neg(d[2]), d[1], new(big.Rat).Sub(cj[0], ci[0])},
+			[]*big.Rat{neg(w[2]), rat(0), w[0], d[2], rat(0), neg(d[0]), new(big.Rat).Sub(cj[1], ci[1])},
+			[]*big.Rat{w[1], neg(w[0]), rat(0), neg(d[1]), d[0], rat(0), new(big.Rat).Sub(cj[2], ci[2])},
+		)
 	}
 
-	return 0
+	solution, err := solveLinear(rows, 6)
+	if err != nil {
+		return rock{}, fmt.Errorf("no single rock throw: %w", err)
+	}
+
+	r := rock{
+		p: [3]*big.Rat{solution[0], solution[1], solution[2]},
+		v: [3]*big.Rat{solution[3], solution[4], solution[5]},
+	}
+	if !r.hits(h) {
+		return r, fmt.Errorf("rock %s misses a hailstone", r)
+	}
+
+	return r, nil
 }
 
 func main() {
+	low := flag.Int64("min", 200000000000000, "lowest x and y of the part 1 test area")
+	high := flag.Int64("max", 400000000000000, "highest x and y of the part 1 test area")
+	list := flag.Bool("collisions", false, "list the pairs of hailstones that collide in all of x, y and z, and when, instead of solving")
+	flag.Parse()
+
+	if *low > *high {
+		fmt.Fprintf(os.Stderr, "test area from %d to %d is empty\n", *low, *high)
+		os.Exit(1)
+	}
+
 	input := parseInput()
 
-	fmt.Println("Part 1:", part1(input))
-	fmt.Println("Part 2:", part2(input))
+	if *list {
+		found := collisions(input)
+		for _, c := range found {
+			a := input[c.a]
+			pos := make([]string, 3)
+			for axis := range pos {
+				pos[axis] = a.at(axis, c.t).RatString()
+			}
+			fmt.Printf("hailstones %d (%s) and %d (%s) collide at t=%s at %s\n",
+				c.a+1, a, c.b+1, input[c.b], c.t.RatString(), strings.Join(pos, ", "))
+		}
+		fmt.Println(len(found), "collisions")
+		return
+	}
+
+	fmt.Println("Part 1:", part1(input, *low, *high))
+
+	r, err := part2(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	sum := new(big.Rat).Add(r.p[0], r.p[1])
+	fmt.Println("Rock:", r)
+	fmt.Println("Part 2:", sum.Add(sum, r.p[2]).RatString())
 }
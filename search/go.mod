@@ -0,0 +1,3 @@
+module github.com/nickshine/adventofcode2023/search
+
+go 1.21.4
@@ -0,0 +1,41 @@
+package search
+
+import "container/heap"
+
+type item[S comparable] struct {
+	state    S
+	cost     int // cost of reaching state
+	priority int // cost plus any heuristic estimate of the remaining cost
+}
+
+// priority queue - https://pkg.go.dev/container/heap
+type queue[S comparable] []item[S]
+
+func (q queue[S]) Len() int           { return len(q) }
+func (q queue[S]) Less(i, j int) bool { return q[i].priority < q[j].priority }
+func (q queue[S]) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *queue[S]) Push(x any) {
+	*q = append(*q, x.(item[S]))
+}
+
+func (q *queue[S]) Pop() any {
+	old := *q
+	n := len(old)
+	it := old[n-1]
+	*q = old[0 : n-1]
+	return it
+}
+
+func (q *queue[S]) push(it item[S]) {
+	heap.Push(q, it)
+}
+
+func (q *queue[S]) pop() item[S] {
+	return heap.Pop(q).(item[S])
+}
+
+// peek returns the priority of the next item, which must exist.
+func (q queue[S]) peek() int {
+	return q[0].priority
+}
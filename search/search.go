@@ -0,0 +1,253 @@
+// Package search provides weighted shortest-path searches over any comparable state type. States
+// and their connections are described by a neighbors function, so the same searches work for grid
+// positions, positions with direction and momentum, or whole puzzle configurations.
+package search
+
+import "slices"
+
+// Edge is a step to a neighboring state and the (non-negative) cost of taking it.
+type Edge[S comparable] struct {
+	To   S
+	Cost int
+}
+
+// Result is the cheapest way found to reach a goal.
+type Result[S comparable] struct {
+	Cost int
+	Path []S // from a start to the goal, inclusive
+}
+
+// Dijkstra returns the cheapest path from any of the starts to a state satisfying goal. The
+// boolean is false if no goal can be reached.
+func Dijkstra[S comparable](starts []S, neighbors func(S) []Edge[S], goal func(S) bool) (Result[S], bool) {
+	return AStar(starts, neighbors, goal, func(S) int { return 0 })
+}
+
+// AStar is Dijkstra guided by heuristic, an estimate of the remaining cost from a state to the
+// nearest goal. The heuristic must never overestimate for the result to be the cheapest path.
+func AStar[S comparable](starts []S, neighbors func(S) []Edge[S], goal func(S) bool, heuristic func(S) int) (Result[S], bool) {
+	var q queue[S]
+
+	// map of states to minimum cost, and the state each was reached from
+	costs := make(map[S]int)
+	prev := make(map[S]S)
+
+	for _, s := range starts {
+		costs[s] = 0
+		q.push(item[S]{state: s, cost: 0, priority: heuristic(s)})
+	}
+
+	for q.Len() > 0 {
+		cur := q.pop()
+		// abandon path if there is already a lower cost path
+		if costs[cur.state] < cur.cost {
+			continue
+		}
+
+		if goal(cur.state) {
+			return Result[S]{Cost: cur.cost, Path: walk(prev, cur.state)}, true
+		}
+
+		for _, e := range neighbors(cur.state) {
+			next := cur.cost + e.Cost
+			if cost, ok := costs[e.To]; ok && cost <= next {
+				continue
+			}
+
+			costs[e.To] = next
+			prev[e.To] = cur.state
+			q.push(item[S]{state: e.To, cost: next, priority: next + heuristic(e.To)})
+		}
+	}
+
+	return Result[S]{}, false
+}
+
+// walk follows prev links back from s to a start, returning the path in forward order.
+func walk[S comparable](prev map[S]S, s S) []S {
+	path := []S{s}
+	for {
+		p, ok := prev[s]
+		if !ok {
+			break
+		}
+		path = append(path, p)
+		s = p
+	}
+	slices.Reverse(path)
+
+	return path
+}
+
+// Distances returns the minimum cost of reaching every state reachable from the starts.
+func Distances[S comparable](starts []S, neighbors func(S) []Edge[S]) map[S]int {
+	var q queue[S]
+	costs := make(map[S]int)
+
+	for _, s := range starts {
+		costs[s] = 0
+		q.push(item[S]{state: s})
+	}
+
+	for q.Len() > 0 {
+		cur := q.pop()
+		if costs[cur.state] < cur.cost {
+			continue
+		}
+
+		for _, e := range neighbors(cur.state) {
+			next := cur.cost + e.Cost
+			if cost, ok := costs[e.To]; ok && cost <= next {
+				continue
+			}
+
+			costs[e.To] = next
+			q.push(item[S]{state: e.To, cost: next, priority: next})
+		}
+	}
+
+	return costs
+}
+
+// Bidirectional searches forward from start and backward from goal at the same time, meeting in
+// the middle. reverse must return the edges leading into a state, i.e. for every edge a -> b with
+// cost c from neighbors(a), reverse(b) includes an edge to a with cost c.
+func Bidirectional[S comparable](start, goal S, neighbors, reverse func(S) []Edge[S]) (Result[S], bool) {
+	type side struct {
+		q     queue[S]
+		costs map[S]int
+		prev  map[S]S // forward: predecessor, backward: successor
+		edges func(S) []Edge[S]
+	}
+
+	fwd := &side{costs: map[S]int{start: 0}, prev: make(map[S]S), edges: neighbors}
+	bwd := &side{costs: map[S]int{goal: 0}, prev: make(map[S]S), edges: reverse}
+	fwd.q.push(item[S]{state: start})
+	bwd.q.push(item[S]{state: goal})
+
+	best, found := 0, false
+	var meet S
+	if start == goal {
+		best, found, meet = 0, true, start
+	}
+
+	// once the cheapest unexplored paths on both sides together cost at least as much as the best
+	// meeting found so far, no cheaper meeting can exist
+	for fwd.q.Len() > 0 && bwd.q.Len() > 0 {
+		if found && fwd.q.peek()+bwd.q.peek() >= best {
+			break
+		}
+
+		this, other := fwd, bwd
+		if bwd.q.Len() < fwd.q.Len() {
+			this, other = bwd, fwd
+		}
+
+		cur := this.q.pop()
+		if this.costs[cur.state] < cur.cost {
+			continue
+		}
+
+		for _, e := range this.edges(cur.state) {
+			next := cur.cost + e.Cost
+			if cost, ok := this.costs[e.To]; ok && cost <= next {
+				continue
+			}
+
+			this.costs[e.To] = next
+			this.prev[e.To] = cur.state
+			this.q.push(item[S]{state: e.To, cost: next, priority: next})
+
+			if cost, ok := other.costs[e.To]; ok && (!found || next+cost < best) {
+				best, found, meet = next+cost, true, e.To
+			}
+		}
+	}
+
+	if !found {
+		return Result[S]{}, false
+	}
+
+	path := walk(fwd.prev, meet)
+	for s := meet; s != goal; {
+		s = bwd.prev[s]
+		path = append(path, s)
+	}
+
+	return Result[S]{Cost: best, Path: path}, true
+}
+
+// AllShortest returns every cheapest path from any of the starts to a state satisfying goal,
+// along with their shared cost. The boolean is false if no goal can be reached. Edge costs must be
+// positive, and the number of paths can grow exponentially with the number of ties, so this is
+// best kept to small searches.
+func AllShortest[S comparable](starts []S, neighbors func(S) []Edge[S], goal func(S) bool) (int, [][]S, bool) {
+	var q queue[S]
+
+	// every state a state can be reached from at its minimum cost
+	costs := make(map[S]int)
+	prevs := make(map[S][]S)
+
+	for _, s := range starts {
+		if _, ok := costs[s]; ok {
+			continue // a repeated start would reach every goal it leads to twice
+		}
+		costs[s] = 0
+		q.push(item[S]{state: s})
+	}
+
+	best, found := 0, false
+	var goals []S
+	for q.Len() > 0 {
+		cur := q.pop()
+		if costs[cur.state] < cur.cost {
+			continue
+		}
+		if found && cur.cost > best {
+			break
+		}
+
+		if goal(cur.state) {
+			best, found = cur.cost, true
+			goals = append(goals, cur.state)
+			continue
+		}
+
+		for _, e := range neighbors(cur.state) {
+			next := cur.cost + e.Cost
+			cost, ok := costs[e.To]
+			switch {
+			case !ok || next < cost:
+				costs[e.To] = next
+				prevs[e.To] = []S{cur.state}
+				q.push(item[S]{state: e.To, cost: next, priority: next})
+			case next == cost && !slices.Contains(prevs[e.To], cur.state):
+				prevs[e.To] = append(prevs[e.To], cur.state)
+			}
+		}
+	}
+
+	if !found {
+		return 0, nil, false
+	}
+
+	var paths [][]S
+	var collect func(s S, suffix []S)
+	collect = func(s S, suffix []S) {
+		suffix = append(suffix, s)
+		if len(prevs[s]) == 0 {
+			path := slices.Clone(suffix)
+			slices.Reverse(path)
+			paths = append(paths, path)
+			return
+		}
+		for _, p := range prevs[s] {
+			collect(p, suffix)
+		}
+	}
+	for _, g := range goals {
+		collect(g, nil)
+	}
+
+	return best, paths, true
+}
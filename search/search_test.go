@@ -0,0 +1,211 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// graph is a small directed graph on states 0..n-1, possibly with several edges between the same
+// pair of states.
+type graph struct {
+	n     int
+	edges [][]Edge[int]
+}
+
+func randomGraph(rng *rand.Rand, minCost, maxCost int) graph {
+	g := graph{n: 1 + rng.Intn(8)}
+	g.edges = make([][]Edge[int], g.n)
+	for e := rng.Intn(3 * g.n); e > 0; e-- {
+		from, to := rng.Intn(g.n), rng.Intn(g.n)
+		g.edges[from] = append(g.edges[from], Edge[int]{To: to, Cost: minCost + rng.Intn(maxCost-minCost+1)})
+	}
+	return g
+}
+
+func (g graph) neighbors(s int) []Edge[int] {
+	return g.edges[s]
+}
+
+// reverse returns the edges leading into s.
+func (g graph) reverse(s int) []Edge[int] {
+	var in []Edge[int]
+	for from, edges := range g.edges {
+		for _, e := range edges {
+			if e.To == s {
+				in = append(in, Edge[int]{To: from, Cost: e.Cost})
+			}
+		}
+	}
+	return in
+}
+
+// pathCost returns the cost of following path by the cheapest edge at each step, or an error if
+// some step has no edge.
+func (g graph) pathCost(path []int) (int, error) {
+	total := 0
+	for i := 1; i < len(path); i++ {
+		cheapest := -1
+		for _, e := range g.edges[path[i-1]] {
+			if e.To == path[i] && (cheapest < 0 || e.Cost < cheapest) {
+				cheapest = e.Cost
+			}
+		}
+		if cheapest < 0 {
+			return 0, fmt.Errorf("no edge from %d to %d", path[i-1], path[i])
+		}
+		total += cheapest
+	}
+	return total, nil
+}
+
+// checkPath verifies that path runs from one of the starts to a goal and costs what was claimed.
+func checkPath(t *testing.T, g graph, name string, starts []int, isGoal func(int) bool, r Result[int]) {
+	t.Helper()
+	if len(r.Path) == 0 || !slices.Contains(starts, r.Path[0]) || !isGoal(r.Path[len(r.Path)-1]) {
+		t.Fatalf("%s: path %v does not run from a start in %v to a goal", name, r.Path, starts)
+	}
+	cost, err := g.pathCost(r.Path)
+	if err != nil {
+		t.Fatalf("%s: path %v: %v", name, r.Path, err)
+	}
+	if cost != r.Cost {
+		t.Fatalf("%s: path %v costs %d, claimed %d", name, r.Path, cost, r.Cost)
+	}
+}
+
+// cheapest returns the lowest cost in dist of any goal, and whether any goal was reached.
+func cheapest(dist map[int]int, goals []int) (int, bool) {
+	best, found := 0, false
+	for _, s := range goals {
+		if d, ok := dist[s]; ok && (!found || d < best) {
+			best, found = d, true
+		}
+	}
+	return best, found
+}
+
+func TestSearchesMatchDistances(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		g := randomGraph(rng, 0, 4)
+		starts := []int{rng.Intn(g.n), rng.Intn(g.n)}
+		goals := []int{rng.Intn(g.n), rng.Intn(g.n)}
+		isGoal := func(s int) bool { return slices.Contains(goals, s) }
+
+		want, reachable := cheapest(Distances(starts, g.neighbors), goals)
+
+		// an admissible heuristic: half the true remaining cost
+		toGoal := Distances(goals, g.reverse)
+		heuristic := func(s int) int { return toGoal[s] / 2 }
+
+		for name, search := range map[string]func() (Result[int], bool){
+			"Dijkstra": func() (Result[int], bool) { return Dijkstra(starts, g.neighbors, isGoal) },
+			"AStar":    func() (Result[int], bool) { return AStar(starts, g.neighbors, isGoal, heuristic) },
+		} {
+			r, ok := search()
+			if ok != reachable || ok && r.Cost != want {
+				t.Fatalf("graph %d: %s gave cost %d (%v), want %d (%v)", i, name, r.Cost, ok, want, reachable)
+			}
+			if ok {
+				checkPath(t, g, fmt.Sprintf("graph %d: %s", i, name), starts, isGoal, r)
+			}
+		}
+
+		start, goal := starts[0], goals[0]
+		want, reachable = cheapest(Distances([]int{start}, g.neighbors), []int{goal})
+		r, ok := Bidirectional(start, goal, g.neighbors, g.reverse)
+		if ok != reachable || ok && r.Cost != want {
+			t.Fatalf("graph %d: Bidirectional from %d to %d gave cost %d (%v), want %d (%v)", i, start, goal, r.Cost, ok, want, reachable)
+		}
+		if ok {
+			checkPath(t, g, fmt.Sprintf("graph %d: Bidirectional", i), []int{start}, func(s int) bool { return s == goal }, r)
+		}
+	}
+}
+
+// bruteForcePaths returns every distinct sequence of states from a start to a goal costing at most
+// limit, stopping at the first goal. Edge costs must be positive so every walk ends.
+func bruteForcePaths(g graph, starts []int, isGoal func(int) bool, limit int) map[string]int {
+	paths := make(map[string]int)
+	var walk func(path []int, cost int)
+	walk = func(path []int, cost int) {
+		s := path[len(path)-1]
+		if isGoal(s) {
+			paths[fmt.Sprint(path)] = cost
+			return
+		}
+		for _, e := range g.edges[s] {
+			if cost+e.Cost <= limit {
+				walk(append(slices.Clone(path), e.To), cost+e.Cost)
+			}
+		}
+	}
+	for _, s := range starts {
+		walk([]int{s}, 0)
+	}
+	return paths
+}
+
+func checkAllShortest(t *testing.T, name string, g graph, starts []int, isGoal func(int) bool, want int, reachable bool) {
+	t.Helper()
+	cost, paths, ok := AllShortest(starts, g.neighbors, isGoal)
+	if ok != reachable || ok && cost != want {
+		t.Fatalf("%s: AllShortest gave cost %d (%v), want %d (%v)", name, cost, ok, want, reachable)
+	}
+	if !ok {
+		return
+	}
+
+	brute := 0
+	for _, c := range bruteForcePaths(g, starts, isGoal, want) {
+		if c == want {
+			brute++
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		checkPath(t, g, name, starts, isGoal, Result[int]{Cost: cost, Path: p})
+		if seen[fmt.Sprint(p)] {
+			t.Fatalf("%s: path %v returned twice", name, p)
+		}
+		seen[fmt.Sprint(p)] = true
+	}
+	if len(paths) != brute {
+		t.Fatalf("%s: AllShortest found %d paths, brute force found %d", name, len(paths), brute)
+	}
+}
+
+func TestAllShortestGrid(t *testing.T) {
+	// a 3x3 grid with unit steps right and down has 6 cheapest paths from corner to corner
+	g := graph{n: 9, edges: make([][]Edge[int], 9)}
+	for s := 0; s < 9; s++ {
+		if s%3 < 2 {
+			g.edges[s] = append(g.edges[s], Edge[int]{To: s + 1, Cost: 1})
+		}
+		if s/3 < 2 {
+			g.edges[s] = append(g.edges[s], Edge[int]{To: s + 3, Cost: 1})
+		}
+	}
+
+	checkAllShortest(t, "grid", g, []int{0}, func(s int) bool { return s == 8 }, 4, true)
+	if _, paths, _ := AllShortest([]int{0}, g.neighbors, func(s int) bool { return s == 8 }); len(paths) != 6 {
+		t.Fatalf("grid: found %d paths, want 6", len(paths))
+	}
+}
+
+func TestAllShortestRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		// costs of 1 or 2 give plenty of ties
+		g := randomGraph(rng, 1, 2)
+		starts := []int{rng.Intn(g.n), rng.Intn(g.n)}
+		goals := []int{rng.Intn(g.n), rng.Intn(g.n)}
+		isGoal := func(s int) bool { return slices.Contains(goals, s) }
+
+		want, reachable := cheapest(Distances(starts, g.neighbors), goals)
+		checkAllShortest(t, fmt.Sprintf("graph %d", i), g, starts, isGoal, want, reachable)
+	}
+}